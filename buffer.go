@@ -0,0 +1,177 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// OverflowPolicy decides what a Buffer does with an item that arrives while it is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops taking items until there is room, holding the producer up.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest throws away the item that has just arrived.
+	OverflowDropNewest
+	// OverflowDropOldest throws away the item that has waited longest to make room for the one that has just arrived.
+	OverflowDropOldest
+	// OverflowError fails the Buffer with ErrBufferOverflow, closing its Out stream once the items it holds are sent.
+	// Items arriving after it has failed are read and discarded until in is closed or ctx is cancelled, so that the
+	// producer isn't left blocked.
+	OverflowError
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowError:
+		return "Error"
+	default:
+		return "Unknown"
+	}
+}
+
+var ErrBufferOverflow = errors.New("buffer overflow")
+
+type BufferStats struct {
+	// Len is the number of items held.
+	Len int
+	// Overflows is the number of items that arrived while the buffer was full, and so were dropped or failed it.
+	Overflows uint64
+}
+
+// BufferStage decouples a producer from its consumer by holding up to size items between them.
+type BufferStage[T any] struct {
+	outStream chan T
+
+	mu        sync.Mutex
+	len       int
+	overflows uint64
+	err       error
+}
+
+// Buffer reads from in and holds up to size items for whoever reads its Out stream, handling items that arrive while
+// it is full according to policy.
+func Buffer[T any](ctx context.Context, in <-chan T, size int, policy OverflowPolicy) *BufferStage[T] {
+	if in == nil {
+		panic("Buffer: in arg has nil value")
+	}
+
+	if size < 1 {
+		panic("Buffer: size must be at least 1")
+	}
+
+	b := &BufferStage[T]{outStream: make(chan T)}
+	observer := ObserverFromContext(ctx)
+
+	go func() {
+		if b.run(ctx, in, size, policy, observer) {
+			// the producer may still be sending, so keep reading in rather than leave it blocked forever
+			_ = Drain(ctx, in)
+		}
+	}()
+
+	return b
+}
+
+// run buffers items from in until in is closed and every held item has been sent, or ctx is cancelled, closing the out
+// stream as it returns. It reports whether the buffer failed with ErrBufferOverflow.
+func (b *BufferStage[T]) run(ctx context.Context, in <-chan T, size int, policy OverflowPolicy, observer Observer) (failed bool) {
+	defer close(b.outStream)
+
+	queue := make([]T, 0, size)
+	for {
+		recv := in
+		if len(queue) >= size && policy == OverflowBlock {
+			recv = nil
+		}
+		var send chan T
+		var head T
+		if len(queue) > 0 {
+			send, head = b.outStream, queue[0]
+		}
+		if send == nil && (recv == nil || failed) {
+			return failed
+		}
+
+		select {
+		case <-ctx.Done():
+			observer.Cancelled("Buffer")
+			return false
+		case item, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			if failed {
+				continue
+			}
+			observer.ItemIn("Buffer")
+			if len(queue) < size {
+				queue = append(queue, item)
+				b.setLen(len(queue))
+				continue
+			}
+
+			b.overflow()
+			switch policy {
+			case OverflowDropOldest:
+				var zero T
+				queue[0] = zero
+				queue = append(queue[1:], item)
+			case OverflowError:
+				b.fail(ErrBufferOverflow)
+				failed = true
+			}
+		case send <- head:
+			observer.ItemOut("Buffer")
+			var zero T
+			queue[0] = zero
+			queue = queue[1:]
+			b.setLen(len(queue))
+		}
+	}
+}
+
+// Out returns the stream of buffered items. It is closed once in is closed and every held item has been sent, or when
+// ctx is cancelled.
+func (b *BufferStage[T]) Out() <-chan T {
+	return b.outStream
+}
+
+func (b *BufferStage[T]) Stats() BufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BufferStats{Len: b.len, Overflows: b.overflows}
+}
+
+// Err returns ErrBufferOverflow if an OverflowError buffer has overflowed, or nil otherwise.
+func (b *BufferStage[T]) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *BufferStage[T]) setLen(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.len = n
+}
+
+func (b *BufferStage[T]) overflow() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.overflows++
+}
+
+func (b *BufferStage[T]) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
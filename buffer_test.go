@@ -0,0 +1,144 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffer(t *testing.T) {
+	// fill sends items to a Buffer that nobody is reading from, then closes in and returns everything it held
+	fill := func(policy OverflowPolicy, size int, items ...int) ([]int, *BufferStage[int]) {
+		in := make(chan int)
+		b := Buffer(context.Background(), in, size, policy)
+		for _, item := range items {
+			in <- item
+		}
+		close(in)
+
+		var out []int
+		for item := range b.Out() {
+			out = append(out, item)
+		}
+		return out, b
+	}
+
+	t.Run("when in has a nil value or size is below 1, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { Buffer[int](context.Background(), nil, 1, OverflowBlock) })
+		assert.Panics(t, func() { Buffer(context.Background(), make(chan int), 0, OverflowBlock) })
+	})
+
+	t.Run("when the buffer isn't full, the producer should not wait for the consumer", func(t *testing.T) {
+		out, b := fill(OverflowBlock, 3, 1, 2, 3)
+		assert.Equal(t, []int{1, 2, 3}, out)
+		assert.Equal(t, BufferStats{}, b.Stats())
+	})
+
+	t.Run("when the policy is Block and the buffer is full, the producer should wait", func(t *testing.T) {
+		in := make(chan int)
+		b := Buffer(context.Background(), in, 2, OverflowBlock)
+		in <- 1
+		in <- 2
+
+		select {
+		case in <- 3:
+			t.Fatal("expected the producer to be held up")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		assert.Equal(t, 1, <-b.Out())
+		in <- 3
+		close(in)
+		assert.Equal(t, []int{2, 3}, []int{<-b.Out(), <-b.Out()})
+		expectClosedChannel(true, b.Out(), t)
+		assert.Equal(t, uint64(0), b.Stats().Overflows)
+	})
+
+	t.Run("when the policy is DropNewest, items arriving while full should be dropped", func(t *testing.T) {
+		out, b := fill(OverflowDropNewest, 2, 1, 2, 3, 4, 5)
+		assert.Equal(t, []int{1, 2}, out)
+		assert.Equal(t, uint64(3), b.Stats().Overflows)
+		assert.NoError(t, b.Err())
+	})
+
+	t.Run("when the policy is DropOldest, the oldest items should make way for new ones", func(t *testing.T) {
+		out, b := fill(OverflowDropOldest, 2, 1, 2, 3, 4, 5)
+		assert.Equal(t, []int{4, 5}, out)
+		assert.Equal(t, uint64(3), b.Stats().Overflows)
+	})
+
+	t.Run("when the policy is Error, the buffer should send what it holds and fail with ErrBufferOverflow", func(t *testing.T) {
+		in := make(chan int)
+		b := Buffer(context.Background(), in, 2, OverflowError)
+		in <- 1
+		in <- 2
+		in <- 3
+
+		var out []int
+		for item := range b.Out() {
+			out = append(out, item)
+		}
+		assert.Equal(t, []int{1, 2}, out)
+		assert.ErrorIs(t, b.Err(), ErrBufferOverflow)
+		assert.Equal(t, uint64(1), b.Stats().Overflows)
+	})
+
+	t.Run("when the policy is Error and the buffer has failed, the producer should not be left blocked", func(t *testing.T) {
+		in := make(chan int)
+		b := Buffer(context.Background(), in, 1, OverflowError)
+
+		sent := make(chan struct{})
+		go func() {
+			defer close(sent)
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			close(in)
+		}()
+
+		<-sent
+		var out []int
+		for item := range b.Out() {
+			out = append(out, item)
+		}
+		assert.Equal(t, []int{0}, out)
+		assert.ErrorIs(t, b.Err(), ErrBufferOverflow)
+		assert.Equal(t, uint64(1), b.Stats().Overflows)
+	})
+
+	t.Run("when ctx is cancelled, the out stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		b := Buffer(ctx, in, 2, OverflowBlock)
+		in <- 1
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			select {
+			case _, ok := <-b.Out():
+				return !ok
+			default:
+				return false
+			}
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("when placed between FanIn and a slow sink, every item should get through in order", func(t *testing.T) {
+		ctx := context.Background()
+		b := Buffer(ctx, FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4, 5}), 1, func(ctx context.Context, in int) int { return in * 10 })), 4, OverflowBlock)
+
+		var out []int
+		for item := range b.Out() {
+			time.Sleep(time.Millisecond)
+			out = append(out, item)
+		}
+		assert.Equal(t, []int{10, 20, 30, 40, 50}, out)
+	})
+}
+
+func TestOverflowPolicyString(t *testing.T) {
+	assert.Equal(t, "DropOldest", OverflowDropOldest.String())
+	assert.Equal(t, "Unknown", OverflowPolicy(42).String())
+}
@@ -0,0 +1,237 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NoCheckpoint is the offset a Checkpointer loads when nothing has been saved yet.
+const NoCheckpoint int64 = -1
+
+// OffsetItem is an item along with its position in its source, so that its completion can be acknowledged.
+type OffsetItem[T any] struct {
+	Offset int64
+	Item   T
+}
+
+// Checkpointer persists the offset up to which a source has been processed, so that a restarted pipeline can resume
+// after it.
+type Checkpointer interface {
+	// Load returns the last offset saved, or NoCheckpoint if none has been.
+	Load(ctx context.Context) (int64, error)
+	Save(ctx context.Context, offset int64) error
+}
+
+// FileCheckpointer keeps the offset in a file, replacing it in a single rename so that a crash mid-save can't
+// corrupt it. The file keeps its mode across saves.
+type FileCheckpointer struct {
+	path string
+}
+
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+func (f *FileCheckpointer) Load(_ context.Context) (int64, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NoCheckpoint, nil
+	}
+	if err != nil {
+		return NoCheckpoint, err
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return NoCheckpoint, fmt.Errorf("failed to parse checkpoint %s: %w", f.path, err)
+	}
+	return offset, nil
+}
+
+func (f *FileCheckpointer) Save(_ context.Context, offset int64) error {
+	tmp, err := createTemp(f.path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(offset, 10) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+type OffsetTrackerOptions struct {
+	// SaveEvery is how many offsets the checkpoint must advance by before it is saved. It defaults to 1, saving every
+	// advance. Offsets not yet saved are processed again after a restart.
+	SaveEvery int
+}
+
+type OffsetTrackerOption func(*OffsetTrackerOptions)
+
+// OffsetTracker records which offsets have been completed, in whatever order, and saves the highest offset below
+// which every offset has been completed. Items are therefore processed at least once: those completed beyond a gap
+// are processed again after a restart.
+type OffsetTracker struct {
+	checkpointer Checkpointer
+	ops          OffsetTrackerOptions
+	start        int64
+
+	mu        sync.Mutex
+	next      int64
+	completed map[int64]struct{}
+	saved     int64
+}
+
+// NewOffsetTracker loads the last checkpoint saved by checkpointer, so that sources can resume after it.
+func NewOffsetTracker(ctx context.Context, checkpointer Checkpointer, options ...OffsetTrackerOption) (*OffsetTracker, error) {
+	if checkpointer == nil {
+		panic("NewOffsetTracker: checkpointer arg has nil value")
+	}
+
+	ops := OffsetTrackerOptions{SaveEvery: 1}
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+	if ops.SaveEvery < 1 {
+		ops.SaveEvery = 1
+	}
+
+	saved, err := checkpointer.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	return &OffsetTracker{
+		checkpointer: checkpointer,
+		ops:          ops,
+		start:        saved + 1,
+		next:         saved + 1,
+		completed:    make(map[int64]struct{}),
+		saved:        saved,
+	}, nil
+}
+
+// Start is the first offset that had not been completed when the tracker was created.
+func (t *OffsetTracker) Start() int64 {
+	return t.start
+}
+
+// Committed is the highest offset below which every offset has been completed, or NoCheckpoint.
+func (t *OffsetTracker) Committed() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.next - 1
+}
+
+// Ack records offset as completed, saving the checkpoint if it has advanced far enough.
+func (t *OffsetTracker) Ack(ctx context.Context, offset int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if offset < t.next {
+		return nil
+	}
+	t.completed[offset] = struct{}{}
+	for {
+		if _, ok := t.completed[t.next]; !ok {
+			break
+		}
+		delete(t.completed, t.next)
+		t.next++
+	}
+
+	if t.next-1-t.saved < int64(t.ops.SaveEvery) {
+		return nil
+	}
+	return t.save(ctx)
+}
+
+// Flush saves the checkpoint if it has advanced since it was last saved, such as when a pipeline finishes.
+func (t *OffsetTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next-1 == t.saved {
+		return nil
+	}
+	return t.save(ctx)
+}
+
+// save must be called with t.mu held.
+func (t *OffsetTracker) save(ctx context.Context) error {
+	if err := t.checkpointer.Save(ctx, t.next-1); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	t.saved = t.next - 1
+	return nil
+}
+
+// GenerateFromSliceCheckpointed returns a stream of the items in list, offset by their index, starting from the
+// tracker's Start.
+func GenerateFromSliceCheckpointed[T any](ctx context.Context, list []T, tracker *OffsetTracker) <-chan OffsetItem[T] {
+	if tracker == nil {
+		panic("GenerateFromSliceCheckpointed: tracker arg has nil value")
+	}
+
+	outStream := make(chan OffsetItem[T])
+	go func() {
+		defer close(outStream)
+		for i := tracker.Start(); i < int64(len(list)); i++ {
+			if !sendOrDone(ctx, outStream, OffsetItem[T]{Offset: i, Item: list[i]}) {
+				return
+			}
+		}
+	}()
+	return outStream
+}
+
+// Resume offsets each item read from in by its position, skipping those before the tracker's Start. It suits sources
+// that replay from the beginning when restarted, such as the lines of a file.
+func Resume[T any](ctx context.Context, in <-chan T, tracker *OffsetTracker) <-chan OffsetItem[T] {
+	if in == nil {
+		panic("Resume: in arg has nil value")
+	}
+
+	if tracker == nil {
+		panic("Resume: tracker arg has nil value")
+	}
+
+	outStream := make(chan OffsetItem[T])
+	go func() {
+		defer close(outStream)
+		var offset int64
+		for item := range OrDone(ctx, in) {
+			if offset >= tracker.Start() {
+				if !sendOrDone(ctx, outStream, OffsetItem[T]{Offset: offset, Item: item}) {
+					return
+				}
+			}
+			offset++
+		}
+	}()
+	return outStream
+}
+
+// WithOffset adapts workerFunc to take and return OffsetItems, carrying each item's offset through to its result.
+func WithOffset[In, Out any](workerFunc WorkerFunc[In, Out]) WorkerFunc[OffsetItem[In], OffsetItem[Out]] {
+	if workerFunc == nil {
+		panic("WithOffset: workerFunc arg has nil value")
+	}
+
+	return func(ctx context.Context, in OffsetItem[In]) OffsetItem[Out] {
+		return OffsetItem[Out]{Offset: in.Offset, Item: workerFunc(ctx, in.Item)}
+	}
+}
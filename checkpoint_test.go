@@ -0,0 +1,163 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCheckpointer records every offset saved.
+type memoryCheckpointer struct {
+	saves []int64
+	err   error
+}
+
+func (m *memoryCheckpointer) Load(context.Context) (int64, error) {
+	if len(m.saves) == 0 {
+		return NoCheckpoint, nil
+	}
+	return m.saves[len(m.saves)-1], nil
+}
+
+func (m *memoryCheckpointer) Save(_ context.Context, offset int64) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.saves = append(m.saves, offset)
+	return nil
+}
+
+func TestFileCheckpointer(t *testing.T) {
+	t.Run("when nothing has been saved, we should load NoCheckpoint", func(t *testing.T) {
+		offset, err := NewFileCheckpointer(filepath.Join(t.TempDir(), "checkpoint")).Load(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, NoCheckpoint, offset)
+	})
+
+	t.Run("when an offset is saved, it should be loaded back without leaving temporary files", func(t *testing.T) {
+		dir := t.TempDir()
+		checkpointer := NewFileCheckpointer(filepath.Join(dir, "checkpoint"))
+		require.NoError(t, checkpointer.Save(context.Background(), 41))
+		require.NoError(t, checkpointer.Save(context.Background(), 42))
+
+		offset, err := checkpointer.Load(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), offset)
+
+		entries, _ := os.ReadDir(dir)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("when an offset is saved over an existing checkpoint, the file should keep its mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint")
+		require.NoError(t, os.WriteFile(path, []byte("1\n"), 0o600))
+		require.NoError(t, os.Chmod(path, 0o640))
+
+		require.NoError(t, NewFileCheckpointer(path).Save(context.Background(), 42))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("when the file is corrupt, we should receive an error", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint")
+		require.NoError(t, os.WriteFile(path, []byte("forty two"), 0o644))
+		_, err := NewFileCheckpointer(path).Load(context.Background())
+		assert.ErrorContains(t, err, "failed to parse checkpoint")
+	})
+}
+
+func TestOffsetTracker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("when checkpointer has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { _, _ = NewOffsetTracker(ctx, nil) })
+	})
+
+	t.Run("when offsets are acked out of order, only the highest contiguous one should be saved", func(t *testing.T) {
+		checkpointer := &memoryCheckpointer{}
+		tracker, err := NewOffsetTracker(ctx, checkpointer)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), tracker.Start())
+
+		require.NoError(t, tracker.Ack(ctx, 1))
+		require.NoError(t, tracker.Ack(ctx, 2))
+		assert.Equal(t, NoCheckpoint, tracker.Committed())
+		assert.Empty(t, checkpointer.saves)
+
+		require.NoError(t, tracker.Ack(ctx, 0))
+		require.NoError(t, tracker.Ack(ctx, 0))
+		assert.Equal(t, int64(2), tracker.Committed())
+		assert.Equal(t, []int64{2}, checkpointer.saves)
+	})
+
+	t.Run("when SaveEvery is set, the checkpoint should be saved less often and flushed at the end", func(t *testing.T) {
+		checkpointer := &memoryCheckpointer{}
+		tracker, _ := NewOffsetTracker(ctx, checkpointer, func(ops *OffsetTrackerOptions) { ops.SaveEvery = 2 })
+		for i := int64(0); i < 5; i++ {
+			require.NoError(t, tracker.Ack(ctx, i))
+		}
+		assert.Equal(t, []int64{1, 3}, checkpointer.saves)
+
+		require.NoError(t, tracker.Flush(ctx))
+		require.NoError(t, tracker.Flush(ctx))
+		assert.Equal(t, []int64{1, 3, 4}, checkpointer.saves)
+	})
+
+	t.Run("when the checkpointer fails, Ack should return its error", func(t *testing.T) {
+		tracker, _ := NewOffsetTracker(ctx, &memoryCheckpointer{err: errors.New("disk full")})
+		assert.ErrorContains(t, tracker.Ack(ctx, 0), "disk full")
+	})
+}
+
+func TestCheckpointedSources(t *testing.T) {
+	ctx := context.Background()
+	list := []string{"a", "b", "c", "d", "e"}
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { GenerateFromSliceCheckpointed[int](ctx, nil, nil) })
+		assert.Panics(t, func() { Resume[int](ctx, nil, &OffsetTracker{}) })
+		assert.Panics(t, func() { Resume[int](ctx, make(chan int), nil) })
+		assert.Panics(t, func() { WithOffset[int, int](nil) })
+	})
+
+	t.Run("when a pipeline crashes part way through, a restarted one should resume after the last checkpoint", func(t *testing.T) {
+		checkpointer := NewFileCheckpointer(filepath.Join(t.TempDir(), "checkpoint"))
+		upper := WithOffset[string, string](func(ctx context.Context, in string) string { return in + "!" })
+
+		// the first run completes "a", "b" and "d" before crashing
+		tracker, err := NewOffsetTracker(ctx, checkpointer)
+		require.NoError(t, err)
+		for _, offset := range []int64{0, 1, 3} {
+			require.NoError(t, tracker.Ack(ctx, offset))
+		}
+
+		tracker, err = NewOffsetTracker(ctx, checkpointer)
+		require.NoError(t, err)
+		var got []string
+		for res := range FanIn(ctx, FanOut(ctx, GenerateFromSliceCheckpointed(ctx, list, tracker), 2, upper)) {
+			got = append(got, res.Item)
+			require.NoError(t, tracker.Ack(ctx, res.Offset))
+		}
+		sort.Strings(got)
+		assert.Equal(t, []string{"c!", "d!", "e!"}, got)
+		assert.Equal(t, int64(4), tracker.Committed())
+	})
+
+	t.Run("when a replayed source is resumed, the items before the checkpoint should be skipped", func(t *testing.T) {
+		checkpointer := &memoryCheckpointer{saves: []int64{2}}
+		tracker, _ := NewOffsetTracker(ctx, checkpointer)
+
+		var got []OffsetItem[string]
+		for item := range Resume(ctx, GenerateFromSlice(ctx, list), tracker) {
+			got = append(got, item)
+		}
+		assert.Equal(t, []OffsetItem[string]{{Offset: 3, Item: "d"}, {Offset: 4, Item: "e"}}, got)
+	})
+}
@@ -0,0 +1,303 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call with ErrCircuitOpen until the CoolDown has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial calls through to decide whether to close or reopen.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitBreakerOptions struct {
+	// FailureRatio is the proportion of failed calls within a Window that opens the circuit.
+	FailureRatio float64
+	// MinRequests is the number of calls that must be made within a Window before the circuit can open.
+	MinRequests int
+	// Window is the period over which calls are counted while the circuit is closed.
+	Window time.Duration
+	// CoolDown is how long the circuit stays open before letting trial calls through.
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of trial calls that must succeed, while half-open, for the circuit to close.
+	HalfOpenRequests int
+
+	// IsFailure decides whether an error counts against the circuit. By default every error other than
+	// context.Canceled does. A cancelled call that isn't a failure counts as neither a failure nor a success, as it
+	// says nothing about whether what it called is healthy, but still frees its half-open slot.
+	IsFailure func(error) bool
+	// IsFailureResponse decides whether a response received through Client counts against the circuit. By default
+	// every 5xx response does.
+	IsFailureResponse func(*http.Response) bool
+	// OnStateChange is called every time the circuit changes state.
+	OnStateChange func(from, to CircuitState)
+
+	Clock Clock
+}
+
+type CircuitBreakerOption func(*CircuitBreakerOptions)
+
+// callOutcome is how a call counts towards the state of the circuit.
+type callOutcome int
+
+const (
+	callFailure callOutcome = iota
+	callSuccess
+	// callNeutral is a call that was cancelled, which counts neither way.
+	callNeutral
+)
+
+// CircuitBreaker stops calls being made to something that is failing, giving it time to recover.
+type CircuitBreaker struct {
+	ops CircuitBreakerOptions
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64
+	windowStart time.Time
+	openedAt    time.Time
+	total       int
+	failures    int
+	inFlight    int
+	successes   int
+}
+
+func NewCircuitBreaker(options ...CircuitBreakerOption) *CircuitBreaker {
+	ops := CircuitBreakerOptions{
+		FailureRatio:     0.5,
+		MinRequests:      10,
+		Window:           time.Second * 10,
+		CoolDown:         time.Second * 30,
+		HalfOpenRequests: 1,
+		IsFailure: func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		},
+		IsFailureResponse: func(res *http.Response) bool {
+			return res.StatusCode >= http.StatusInternalServerError
+		},
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	if ops.HalfOpenRequests < 1 {
+		ops.HalfOpenRequests = 1
+	}
+
+	return &CircuitBreaker{
+		ops:         ops,
+		windowStart: ops.Clock.Now(),
+	}
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	from := cb.state
+	cb.advance()
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+	return to
+}
+
+// Execute calls fn if the circuit allows it, recording the outcome, and fails fast with ErrCircuitOpen if it does not.
+// Should fn panic, the call counts as a failure and the panic carries on up the stack.
+func (cb *CircuitBreaker) Execute(fn func() error) error {
+	generation, err := cb.allow()
+	if err != nil {
+		return err
+	}
+
+	// deferred so that a panic still frees the call's half-open slot
+	outcome := callFailure
+	defer func() { cb.done(generation, outcome) }()
+
+	err = fn()
+	outcome = cb.outcomeOf(err)
+	return err
+}
+
+// Client wraps httpClient so that requests fail fast with ErrCircuitOpen while the circuit is open.
+func (cb *CircuitBreaker) Client(httpClient HttpClient) HttpClient {
+	if httpClient == nil {
+		panic("CircuitBreaker.Client: httpClient arg has nil value")
+	}
+	return circuitBreakerClient{cb: cb, httpClient: httpClient}
+}
+
+type circuitBreakerClient struct {
+	cb         *CircuitBreaker
+	httpClient HttpClient
+}
+
+func (c circuitBreakerClient) Do(req *http.Request) (*http.Response, error) {
+	generation, err := c.cb.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := callFailure
+	defer func() { c.cb.done(generation, outcome) }()
+
+	res, err := c.httpClient.Do(req)
+	outcome = c.cb.outcomeOf(err)
+	if err == nil && (res == nil || c.cb.ops.IsFailureResponse(res)) {
+		outcome = callFailure
+	}
+	return res, err
+}
+
+// WithCircuitBreaker wraps workerFunc so that it fails fast with ErrCircuitOpen while cb is open.
+func WithCircuitBreaker[In, Out any](cb *CircuitBreaker, workerFunc ErrWorkerFunc[In, Out]) ErrWorkerFunc[In, Out] {
+	if cb == nil {
+		panic("WithCircuitBreaker: cb arg has nil value")
+	}
+
+	if workerFunc == nil {
+		panic("WithCircuitBreaker: workerFunc arg has nil value")
+	}
+
+	return func(ctx context.Context, in In) (Out, error) {
+		var out Out
+		err := cb.Execute(func() error {
+			var err error
+			out, err = workerFunc(ctx, in)
+			return err
+		})
+		return out, err
+	}
+}
+
+func (cb *CircuitBreaker) allow() (uint64, error) {
+	cb.mu.Lock()
+	from := cb.state
+	cb.advance()
+	to := cb.state
+	generation, err := cb.generation, error(nil)
+	switch cb.state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.inFlight >= cb.ops.HalfOpenRequests {
+			err = ErrCircuitOpen
+		} else {
+			cb.inFlight++
+		}
+	}
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+	return generation, err
+}
+
+func (cb *CircuitBreaker) outcomeOf(err error) callOutcome {
+	switch {
+	case cb.ops.IsFailure(err):
+		return callFailure
+	case errors.Is(err, context.Canceled):
+		return callNeutral
+	default:
+		return callSuccess
+	}
+}
+
+func (cb *CircuitBreaker) done(generation uint64, outcome callOutcome) {
+	cb.mu.Lock()
+	from := cb.state
+	// a call that started before the circuit last changed state says nothing about the current state
+	if generation == cb.generation {
+		switch cb.state {
+		case CircuitClosed:
+			cb.advance()
+			if outcome == callNeutral {
+				break
+			}
+			cb.total++
+			if outcome == callFailure {
+				cb.failures++
+			}
+			if cb.total >= cb.ops.MinRequests && float64(cb.failures)/float64(cb.total) >= cb.ops.FailureRatio {
+				cb.setState(CircuitOpen)
+			}
+		case CircuitHalfOpen:
+			cb.inFlight--
+			if outcome == callNeutral {
+				break
+			}
+			if outcome == callFailure {
+				cb.setState(CircuitOpen)
+				break
+			}
+			cb.successes++
+			if cb.successes >= cb.ops.HalfOpenRequests {
+				cb.setState(CircuitClosed)
+			}
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+}
+
+// advance moves the circuit on with the passage of time. It must be called with cb.mu held.
+func (cb *CircuitBreaker) advance() {
+	now := cb.ops.Clock.Now()
+	switch cb.state {
+	case CircuitClosed:
+		if now.Sub(cb.windowStart) >= cb.ops.Window {
+			cb.windowStart = now
+			cb.total, cb.failures = 0, 0
+		}
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) >= cb.ops.CoolDown {
+			cb.setState(CircuitHalfOpen)
+		}
+	}
+}
+
+// setState must be called with cb.mu held.
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	now := cb.ops.Clock.Now()
+	cb.state = state
+	cb.generation++
+	cb.total, cb.failures, cb.inFlight, cb.successes = 0, 0, 0, 0
+	cb.windowStart = now
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+}
+
+func (cb *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.ops.OnStateChange != nil {
+		cb.ops.OnStateChange(from, to)
+	}
+}
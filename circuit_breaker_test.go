@@ -0,0 +1,283 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	errBoom := errors.New("boom")
+	fail := func() error { return errBoom }
+	succeed := func() error { return nil }
+
+	newBreaker := func(clock Clock, transitions *[]string) *CircuitBreaker {
+		var mu sync.Mutex
+		return NewCircuitBreaker(func(o *CircuitBreakerOptions) {
+			o.FailureRatio = 0.5
+			o.MinRequests = 4
+			o.Window = time.Minute
+			o.CoolDown = time.Second * 10
+			o.HalfOpenRequests = 2
+			o.Clock = clock
+			o.OnStateChange = func(from, to CircuitState) {
+				mu.Lock()
+				defer mu.Unlock()
+				*transitions = append(*transitions, from.String()+"->"+to.String())
+			}
+		})
+	}
+
+	t.Run("when fewer than MinRequests have been made, the circuit should stay closed however many fail", func(t *testing.T) {
+		var transitions []string
+		cb := newBreaker(newFakeClock(), &transitions)
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, cb.Execute(fail), errBoom)
+		}
+		assert.Equal(t, CircuitClosed, cb.State())
+		assert.Empty(t, transitions)
+	})
+
+	t.Run("when the failure ratio is reached, the circuit should open and fail fast", func(t *testing.T) {
+		var transitions []string
+		cb := newBreaker(newFakeClock(), &transitions)
+		require.NoError(t, cb.Execute(succeed))
+		require.NoError(t, cb.Execute(succeed))
+		_ = cb.Execute(fail)
+		_ = cb.Execute(fail)
+
+		assert.Equal(t, CircuitOpen, cb.State())
+		called := false
+		err := cb.Execute(func() error { called = true; return nil })
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.False(t, called)
+		assert.Equal(t, []string{"closed->open"}, transitions)
+	})
+
+	t.Run("when the window passes, earlier failures should no longer count", func(t *testing.T) {
+		var transitions []string
+		clock := newFakeClock()
+		cb := newBreaker(clock, &transitions)
+		_ = cb.Execute(fail)
+		_ = cb.Execute(fail)
+		_ = cb.Execute(fail)
+		clock.Advance(time.Minute)
+		_ = cb.Execute(fail)
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("when the cool down passes and the trial calls succeed, the circuit should close", func(t *testing.T) {
+		var transitions []string
+		clock := newFakeClock()
+		cb := newBreaker(clock, &transitions)
+		for i := 0; i < 4; i++ {
+			_ = cb.Execute(fail)
+		}
+
+		clock.Advance(time.Second * 10)
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+		require.NoError(t, cb.Execute(succeed))
+		require.NoError(t, cb.Execute(succeed))
+
+		assert.Equal(t, CircuitClosed, cb.State())
+		assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, transitions)
+	})
+
+	t.Run("when a trial call fails, the circuit should open again", func(t *testing.T) {
+		var transitions []string
+		clock := newFakeClock()
+		cb := newBreaker(clock, &transitions)
+		for i := 0; i < 4; i++ {
+			_ = cb.Execute(fail)
+		}
+
+		clock.Advance(time.Second * 10)
+		_ = cb.Execute(fail)
+
+		assert.Equal(t, CircuitOpen, cb.State())
+		assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->open"}, transitions)
+	})
+
+	t.Run("when half-open, we should let no more than HalfOpenRequests calls through at once", func(t *testing.T) {
+		var transitions []string
+		clock := newFakeClock()
+		cb := newBreaker(clock, &transitions)
+		for i := 0; i < 4; i++ {
+			_ = cb.Execute(fail)
+		}
+		clock.Advance(time.Second * 10)
+
+		release := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer wg.Done()
+				_ = cb.Execute(func() error { <-release; return nil })
+			}()
+		}
+		assert.Eventually(t, func() bool {
+			return errors.Is(cb.Execute(succeed), ErrCircuitOpen)
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("when a trial call panics, it should count as a failure and not hold on to its half-open slot", func(t *testing.T) {
+		var transitions []string
+		clock := newFakeClock()
+		cb := newBreaker(clock, &transitions)
+		for i := 0; i < 4; i++ {
+			_ = cb.Execute(fail)
+		}
+		clock.Advance(time.Second * 10)
+
+		assert.Panics(t, func() { _ = cb.Execute(func() error { panic("boom") }) })
+		assert.Equal(t, CircuitOpen, cb.State())
+
+		clock.Advance(time.Second * 10)
+		require.NoError(t, cb.Execute(succeed))
+		require.NoError(t, cb.Execute(succeed))
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("when ctx is cancelled, it should not count as a failure", func(t *testing.T) {
+		var transitions []string
+		cb := newBreaker(newFakeClock(), &transitions)
+		for i := 0; i < 4; i++ {
+			_ = cb.Execute(func() error { return context.Canceled })
+		}
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("when calls are cancelled while closed, they should not dilute the failure ratio", func(t *testing.T) {
+		var transitions []string
+		cb := newBreaker(newFakeClock(), &transitions)
+		for i := 0; i < 2; i++ {
+			_ = cb.Execute(fail)
+			_ = cb.Execute(func() error { return context.Canceled })
+			_ = cb.Execute(func() error { return context.Canceled })
+		}
+		_ = cb.Execute(succeed)
+		_ = cb.Execute(fail)
+		assert.Equal(t, CircuitOpen, cb.State())
+	})
+
+	t.Run("when a trial call is cancelled, it should neither close nor reopen the circuit, but free its slot", func(t *testing.T) {
+		var transitions []string
+		clock := newFakeClock()
+		cb := newBreaker(clock, &transitions)
+		for i := 0; i < 4; i++ {
+			_ = cb.Execute(fail)
+		}
+		clock.Advance(time.Second * 10)
+
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, cb.Execute(func() error { return context.Canceled }), context.Canceled)
+		}
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+
+		require.NoError(t, cb.Execute(succeed))
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+		require.NoError(t, cb.Execute(succeed))
+		assert.Equal(t, CircuitClosed, cb.State())
+		assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, transitions)
+	})
+}
+
+func TestCircuitBreakerClient(t *testing.T) {
+	newBreaker := func() *CircuitBreaker {
+		return NewCircuitBreaker(func(o *CircuitBreakerOptions) {
+			o.MinRequests = 2
+			o.Clock = newFakeClock()
+		})
+	}
+
+	t.Run("when httpClient has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { newBreaker().Client(nil) })
+	})
+
+	t.Run("when the server keeps responding with 5xx, requests should start to fail fast", func(t *testing.T) {
+		calls := 0
+		client := newBreaker().Client(HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}))
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			res := <-HttpReqAsync(context.Background(), client, req, func(r *http.Response, err error) (int, error) {
+				if err != nil {
+					return 0, err
+				}
+				return r.StatusCode, nil
+			})
+			assert.Equal(t, http.StatusBadGateway, res.Res)
+		}
+
+		res := <-HttpReqAsync(context.Background(), client, req, func(r *http.Response, err error) (int, error) {
+			if err != nil {
+				return 0, err
+			}
+			return r.StatusCode, nil
+		})
+		assert.ErrorIs(t, res.Error, ErrCircuitOpen)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("when the transport errors, requests should start to fail fast", func(t *testing.T) {
+		errTransport := errors.New("connection refused")
+		client := newBreaker().Client(&http.Client{Transport: newStubRoundTripper(nil, errTransport)})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.Do(req)
+			assert.ErrorIs(t, err, errTransport)
+		}
+		_, err = client.Do(req)
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	t.Run("when cb has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			WithCircuitBreaker[int, int](nil, func(ctx context.Context, in int) (int, error) { return in, nil })
+		})
+	})
+
+	t.Run("when workerFunc has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { WithCircuitBreaker[int, int](NewCircuitBreaker(), nil) })
+	})
+
+	t.Run("when used within FanOut, failing items should open the circuit for those that follow", func(t *testing.T) {
+		cb := NewCircuitBreaker(func(o *CircuitBreakerOptions) {
+			o.MinRequests = 2
+			o.Clock = newFakeClock()
+		})
+		var worker ErrWorkerFunc[int, int] = func(ctx context.Context, in int) (int, error) {
+			return 0, errors.New("downstream unavailable")
+		}
+
+		ctx := context.Background()
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4}), WithCircuitBreaker(cb, worker).Results())
+
+		var open int
+		for res := range outStream {
+			if errors.Is(res.Error, ErrCircuitOpen) {
+				open++
+			}
+		}
+		assert.Equal(t, 2, open)
+	})
+}
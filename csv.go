@@ -0,0 +1,322 @@
+package pipelines
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+type CSVOptions struct {
+	// Comma is the field delimiter, which defaults to ','.
+	Comma rune
+}
+
+type CSVOption func(*CSVOptions)
+
+func csvOptions(options []CSVOption) CSVOptions {
+	ops := CSVOptions{Comma: ','}
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+	return ops
+}
+
+// CSVRowError reports a row of CSV that couldn't be read into its struct.
+type CSVRowError struct {
+	// Row is the number of the row, counting the header as row 1, as a spreadsheet would.
+	Row int
+	// Line is the line of the file that the row starts on, which differs from Row when fields span lines.
+	Line int
+	// Column is the header of the field that couldn't be parsed, if the error is down to one.
+	Column string
+	Err    error
+}
+
+func (e *CSVRowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d: column %s: %v", e.Row, e.Column, e.Err)
+}
+
+func (e *CSVRowError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateCSV reads CSV from r into a stream of T, matching the header row to the fields of T by their csv tags, or by
+// their names for untagged fields. Fields tagged "-" are skipped, as are columns without a field. Fields may be
+// strings, bools, numbers or implement encoding.TextUnmarshaler, such as time.Time, and empty values leave them at
+// zero. A row that can't be read is reported as a *CSVRowError on the error stream, and the rows after it are still
+// read. Both streams must be read, as each waits on its consumer, and both are closed once r is exhausted, r fails or
+// ctx is cancelled.
+func GenerateCSV[T any](ctx context.Context, r io.Reader, options ...CSVOption) (<-chan T, <-chan error) {
+	if r == nil {
+		panic("GenerateCSV: r arg has nil value")
+	}
+
+	fields := csvFields[T]("GenerateCSV")
+	ops := csvOptions(options)
+
+	outStream := make(chan T)
+	errStream := make(chan error)
+
+	go func() {
+		defer close(outStream)
+		defer close(errStream)
+
+		reader := csv.NewReader(r)
+		reader.Comma = ops.Comma
+		reader.FieldsPerRecord = -1
+
+		header, err := reader.Read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				sendOrDone[error](ctx, errStream, &CSVRowError{Row: 1, Line: 1, Err: err})
+			}
+			return
+		}
+
+		// columns[i] is the field that column i is read into, if any
+		columns := make([]*csvField, len(header))
+		for i, name := range header {
+			for j := range fields {
+				if fields[j].name == name {
+					columns[i] = &fields[j]
+				}
+			}
+		}
+
+		for row := 2; ; row++ {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				var parseErr *csv.ParseError
+				if !errors.As(err, &parseErr) {
+					sendOrDone[error](ctx, errStream, &CSVRowError{Row: row, Err: err})
+					return
+				}
+				if !sendOrDone[error](ctx, errStream, &CSVRowError{Row: row, Line: parseErr.StartLine, Err: err}) {
+					return
+				}
+				continue
+			}
+
+			line, _ := reader.FieldPos(0)
+			item, rowErr := decodeCSVRecord[T](record, header, columns)
+			if rowErr != nil {
+				rowErr.Row, rowErr.Line = row, line
+				if !sendOrDone[error](ctx, errStream, rowErr) {
+					return
+				}
+				continue
+			}
+			if !sendOrDone(ctx, outStream, item) {
+				return
+			}
+		}
+	}()
+
+	return outStream, errStream
+}
+
+func decodeCSVRecord[T any](record, header []string, columns []*csvField) (T, *CSVRowError) {
+	var item T
+	v := reflect.ValueOf(&item).Elem()
+	for i, value := range record {
+		if i >= len(columns) || columns[i] == nil || value == "" {
+			continue
+		}
+		if err := parseCSVValue(v.FieldByIndex(columns[i].index), value); err != nil {
+			return item, &CSVRowError{Column: header[i], Err: err}
+		}
+	}
+	return item, nil
+}
+
+// NewCSVStructEncoder writes each item as a row of CSV, after a header row naming the fields as GenerateCSV reads
+// them. The header is written even if there are no items.
+func NewCSVStructEncoder[T any](w io.Writer) Encoder[T] {
+	return newCSVStructEncoder[T](w, csvFields[T]("NewCSVStructEncoder"), ',')
+}
+
+func newCSVStructEncoder[T any](w io.Writer, fields []csvField, comma rune) *csvStructEncoder[T] {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvStructEncoder[T]{w: cw, fields: fields}
+}
+
+type csvStructEncoder[T any] struct {
+	w             *csv.Writer
+	fields        []csvField
+	headerWritten bool
+}
+
+func (e *csvStructEncoder[T]) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	header := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		header[i] = f.name
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvStructEncoder[T]) Encode(item T) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(item)
+	record := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		value, err := formatCSVValue(v.FieldByIndex(f.index))
+		if err != nil {
+			return fmt.Errorf("column %s: %w", f.name, err)
+		}
+		record[i] = value
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvStructEncoder[T]) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ToCSV writes every item read from in to w as CSV, as NewCSVStructEncoder does, until in is closed. It returns the
+// first error from writing, or ctx's error if ctx is cancelled first.
+func ToCSV[T any](ctx context.Context, in <-chan T, w io.Writer, options ...CSVOption) error {
+	if in == nil {
+		panic("ToCSV: in arg has nil value")
+	}
+
+	if w == nil {
+		panic("ToCSV: w arg has nil value")
+	}
+
+	fields := csvFields[T]("ToCSV")
+	ops := csvOptions(options)
+	return ToWriter(ctx, in, w, func(w io.Writer) Encoder[T] {
+		return newCSVStructEncoder[T](w, fields, ops.Comma)
+	})
+}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// csvFields returns the fields of T that are read from and written to CSV, panicking, on behalf of caller, if T isn't
+// a struct or has a field of a type that can't be.
+func csvFields[T any](caller string) []csvField {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("%s: %s is not a struct", caller, t))
+	}
+
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("csv")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if !csvSupported(f.Type) {
+			panic(fmt.Sprintf("%s: field %s has unsupported type %s", caller, f.Name, f.Type))
+		}
+		name := tag
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, csvField{name: name, index: f.Index})
+	}
+	return fields
+}
+
+func csvSupported(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) && t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseCSVValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
+
+func formatCSVValue(v reflect.Value) (string, error) {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", nil
+}
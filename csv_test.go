@@ -0,0 +1,146 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stockItem struct {
+	SKU          string    `csv:"sku"`
+	Name         string    `csv:"name"`
+	Qty          int       `csv:"qty"`
+	Price        float64   `csv:"price"`
+	Discontinued bool      `csv:"discontinued"`
+	Updated      time.Time `csv:"updated"`
+	Warehouse    string
+	Notes        string `csv:"-"`
+	internal     string
+}
+
+// readCSV reads both streams from GenerateCSV until they close.
+func readCSV[T any](items <-chan T, errs <-chan error) ([]T, []error) {
+	var errList []error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for err := range errs {
+			errList = append(errList, err)
+		}
+	}()
+
+	var itemList []T
+	for item := range items {
+		itemList = append(itemList, item)
+	}
+	<-done
+	return itemList, errList
+}
+
+func TestGenerateCSV(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("when any of the required args have a nil value, or T can't be read, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { GenerateCSV[stockItem](ctx, nil) })
+		assert.Panics(t, func() { GenerateCSV[int](ctx, strings.NewReader("")) })
+		assert.Panics(t, func() { GenerateCSV[struct{ Tags []string }](ctx, strings.NewReader("")) })
+	})
+
+	t.Run("when rows are read, the columns should be matched to fields by header", func(t *testing.T) {
+		input := "qty,sku,name,price,discontinued,updated,Warehouse,colour,Notes\n" +
+			"3,A1,Mixer,1299.5,false,2024-03-01T09:00:00Z,Leeds,black,fragile\n" +
+			"0,B2,\"Stage box, 32ch\",,true,,,,\n"
+
+		items, errs := readCSV(GenerateCSV[stockItem](ctx, strings.NewReader(input)))
+		assert.Empty(t, errs)
+		assert.Equal(t, []stockItem{
+			{SKU: "A1", Name: "Mixer", Qty: 3, Price: 1299.5, Updated: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), Warehouse: "Leeds"},
+			{SKU: "B2", Name: "Stage box, 32ch", Discontinued: true},
+		}, items)
+	})
+
+	t.Run("when rows can't be read, we should receive errors with their row numbers and still read the rest", func(t *testing.T) {
+		input := "sku,qty,name\n" +
+			"A1,3,Mixer\n" +
+			"B2,lots,Stage box\n" +
+			"C3,1,\"Fader\npanel\"\n" +
+			"D4,2,Bad \"quote\n" +
+			"E5,7,Monitor\n"
+
+		items, errs := readCSV(GenerateCSV[stockItem](ctx, strings.NewReader(input)))
+		require.Len(t, items, 3)
+		assert.Equal(t, []string{"A1", "C3", "E5"}, []string{items[0].SKU, items[1].SKU, items[2].SKU})
+
+		require.Len(t, errs, 2)
+		var rowErr *CSVRowError
+		require.ErrorAs(t, errs[0], &rowErr)
+		assert.Equal(t, 3, rowErr.Row)
+		assert.Equal(t, 3, rowErr.Line)
+		assert.Equal(t, "qty", rowErr.Column)
+		assert.ErrorIs(t, errs[0], strconv.ErrSyntax)
+		assert.Equal(t, `row 3: column qty: strconv.ParseInt: parsing "lots": invalid syntax`, errs[0].Error())
+
+		require.ErrorAs(t, errs[1], &rowErr)
+		assert.Equal(t, 5, rowErr.Row)
+		assert.Equal(t, 6, rowErr.Line)
+	})
+
+	t.Run("when the input is empty, both streams should be closed", func(t *testing.T) {
+		items, errs := readCSV(GenerateCSV[stockItem](ctx, strings.NewReader("")))
+		assert.Empty(t, items)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("when ctx is cancelled, both streams should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		items, errs := GenerateCSV[stockItem](ctx, strings.NewReader("sku\nA1\nB2\n"))
+		cancel()
+		expectClosedChannel(true, items, t)
+		expectClosedChannel(true, errs, t)
+	})
+}
+
+func TestToCSV(t *testing.T) {
+	ctx := context.Background()
+	stock := []stockItem{
+		{SKU: "A1", Name: "Mixer", Qty: 3, Price: 1299.5, Updated: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), Notes: "dropped"},
+		{SKU: "B2", Name: "Stage box; 32ch", Discontinued: true},
+	}
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { _ = ToCSV[stockItem](ctx, nil, &bytes.Buffer{}) })
+		assert.Panics(t, func() { _ = ToCSV(ctx, make(chan stockItem), nil) })
+	})
+
+	t.Run("when items are written, there should be a header row and a row for each", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, ToCSV(ctx, GenerateFromSlice(ctx, stock), &buf))
+		assert.Equal(t, "sku,name,qty,price,discontinued,updated,Warehouse\n"+
+			"A1,Mixer,3,1299.5,false,2024-03-01T09:00:00Z,\n"+
+			"B2,Stage box; 32ch,0,0,true,0001-01-01T00:00:00Z,\n", buf.String())
+	})
+
+	t.Run("when there are no items, the header should still be written", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, ToCSV(ctx, GenerateFromSlice(ctx, []stockItem{}), &buf))
+		assert.Equal(t, "sku,name,qty,price,discontinued,updated,Warehouse\n", buf.String())
+	})
+
+	t.Run("when written items are read back with the same Comma, they should be unchanged", func(t *testing.T) {
+		semicolon := func(ops *CSVOptions) { ops.Comma = ';' }
+		var buf bytes.Buffer
+		require.NoError(t, ToCSV(ctx, GenerateFromSlice(ctx, stock), &buf, semicolon))
+
+		items, errs := readCSV(GenerateCSV[stockItem](ctx, &buf, semicolon))
+		assert.Empty(t, errs)
+		want := append([]stockItem(nil), stock...)
+		want[0].Notes = ""
+		assert.Equal(t, want, items)
+	})
+}
@@ -0,0 +1,310 @@
+package pipelines
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an item that a worker failed on, along with why.
+type DeadLetter[T any] struct {
+	Item T
+	Err  error
+	// Attempts is the number of times the item was tried, taken from Err if it has an Attempts method, or else 1.
+	Attempts int
+	Time     time.Time
+}
+
+type deadLetterJSON[T any] struct {
+	Item     T         `json:"item"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+}
+
+// MarshalJSON writes Err as its message, as errors in general can't be written as JSON.
+func (d DeadLetter[T]) MarshalJSON() ([]byte, error) {
+	var msg string
+	if d.Err != nil {
+		msg = d.Err.Error()
+	}
+	return json.Marshal(deadLetterJSON[T]{Item: d.Item, Error: msg, Attempts: d.Attempts, Time: d.Time})
+}
+
+// UnmarshalJSON reads back what MarshalJSON writes, with Err holding only the error's message.
+func (d *DeadLetter[T]) UnmarshalJSON(data []byte) error {
+	var j deadLetterJSON[T]
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*d = DeadLetter[T]{Item: j.Item, Attempts: j.Attempts, Time: j.Time}
+	if j.Error != "" {
+		d.Err = errors.New(j.Error)
+	}
+	return nil
+}
+
+// DeadLetterSink takes the items that workers have failed on, so that they are not lost.
+type DeadLetterSink[T any] interface {
+	Put(ctx context.Context, letter DeadLetter[T]) error
+}
+
+// DeadLetterReader is implemented by sinks whose dead letters can be taken back out, such as for Replay.
+type DeadLetterReader[T any] interface {
+	DeadLetterSink[T]
+	// TakeDeadLetters returns the dead letters held, in the order they were put, and removes them from the sink.
+	TakeDeadLetters() ([]DeadLetter[T], error)
+}
+
+type DeadLetterOptions struct {
+	Clock Clock
+}
+
+type DeadLetterOption func(*DeadLetterOptions)
+
+func deadLetterOptions(options []DeadLetterOption) DeadLetterOptions {
+	ops := DeadLetterOptions{
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	return ops
+}
+
+// WithDeadLetter wraps workerFunc so that each item it fails on is put in sink. The error is still returned, so the
+// failure can be handled downstream as well. Items that fail once ctx is cancelled aren't put, as the failure is more
+// likely down to the cancellation than to the item. Should sink fail, its error is logged to the ctx Logger.
+func WithDeadLetter[In, Out any](sink DeadLetterSink[In], workerFunc ErrWorkerFunc[In, Out], options ...DeadLetterOption) ErrWorkerFunc[In, Out] {
+	if sink == nil {
+		panic("WithDeadLetter: sink arg has nil value")
+	}
+
+	if workerFunc == nil {
+		panic("WithDeadLetter: workerFunc arg has nil value")
+	}
+
+	ops := deadLetterOptions(options)
+
+	return func(ctx context.Context, in In) (Out, error) {
+		res, err := workerFunc(ctx, in)
+		if err != nil {
+			putDeadLetter(ctx, sink, ops, in, err)
+		}
+		return res, err
+	}
+}
+
+// DeadLetterTo is a WorkerOption that has FanOut and WorkerThread put each item that fails in sink, as WithDeadLetter
+// does. An item fails when the WorkerFunc's result is a Result holding an error, or when it runs past the ItemTimeout.
+// sink must take the same type of item as the inStream, or FanOut and WorkerThread panic.
+func DeadLetterTo[T any](sink DeadLetterSink[T], options ...DeadLetterOption) WorkerOption {
+	if sink == nil {
+		panic("DeadLetterTo: sink arg has nil value")
+	}
+
+	d := deadLetterTo[T]{sink: sink, ops: deadLetterOptions(options)}
+	return func(ops *WorkerOptions) {
+		ops.deadLetters = d
+	}
+}
+
+// deadLetterer puts the items a worker fails on in a sink, whatever their type.
+type deadLetterer interface {
+	put(ctx context.Context, item interface{}, err error)
+	itemType() reflect.Type
+}
+
+type deadLetterTo[T any] struct {
+	sink DeadLetterSink[T]
+	ops  DeadLetterOptions
+}
+
+func (d deadLetterTo[T]) put(ctx context.Context, item interface{}, err error) {
+	putDeadLetter(ctx, d.sink, d.ops, item.(T), err)
+}
+
+func (d deadLetterTo[T]) itemType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// checkDeadLetters returns an error if ops has a dead letter sink that doesn't take In items.
+func checkDeadLetters[In any](ops WorkerOptions) error {
+	if ops.deadLetters == nil {
+		return nil
+	}
+	if want, got := reflect.TypeOf((*In)(nil)).Elem(), ops.deadLetters.itemType(); want != got {
+		return fmt.Errorf("dead letter sink takes %s items, not %s", got, want)
+	}
+	return nil
+}
+
+func putDeadLetter[T any](ctx context.Context, sink DeadLetterSink[T], ops DeadLetterOptions, item T, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	letter := DeadLetter[T]{Item: item, Err: err, Attempts: attemptsOf(err), Time: ops.Clock.Now()}
+	if sinkErr := sink.Put(ctx, letter); sinkErr != nil {
+		LoggerFromContext(ctx).Error("failed to put dead letter", "error", sinkErr, "cause", err)
+	}
+}
+
+func attemptsOf(err error) int {
+	var a interface{ Attempts() int }
+	if errors.As(err, &a) {
+		return a.Attempts()
+	}
+	return 1
+}
+
+// Replay takes the dead letters out of r and returns a stream of their items, so that they can be fed back into a
+// pipeline. Items that fail again can so be put back in r without being held twice. Should ctx be cancelled before
+// every item is read from the stream, those left are put back in r.
+func Replay[T any](ctx context.Context, r DeadLetterReader[T]) (<-chan T, error) {
+	if r == nil {
+		panic("Replay: r arg has nil value")
+	}
+
+	letters, err := r.TakeDeadLetters()
+	if err != nil {
+		return nil, err
+	}
+
+	outStream := make(chan T)
+	go func() {
+		defer close(outStream)
+		for i, letter := range letters {
+			if sendOrDone(ctx, outStream, letter.Item) {
+				continue
+			}
+			for _, unsent := range letters[i:] {
+				if err := r.Put(context.WithoutCancel(ctx), unsent); err != nil {
+					LoggerFromContext(ctx).Error("failed to put back dead letter", "error", err)
+				}
+			}
+			return
+		}
+	}()
+	return outStream, nil
+}
+
+// MemoryDeadLetterSink keeps dead letters in memory.
+type MemoryDeadLetterSink[T any] struct {
+	mu      sync.Mutex
+	letters []DeadLetter[T]
+}
+
+func NewMemoryDeadLetterSink[T any]() *MemoryDeadLetterSink[T] {
+	return &MemoryDeadLetterSink[T]{}
+}
+
+func (m *MemoryDeadLetterSink[T]) Put(_ context.Context, letter DeadLetter[T]) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+// DeadLetters returns a copy of the dead letters put so far, in the order they were put.
+func (m *MemoryDeadLetterSink[T]) DeadLetters() ([]DeadLetter[T], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeadLetter[T](nil), m.letters...), nil
+}
+
+func (m *MemoryDeadLetterSink[T]) TakeDeadLetters() ([]DeadLetter[T], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	letters := m.letters
+	m.letters = nil
+	return letters, nil
+}
+
+// JSONLinesDeadLetterSink appends dead letters to a file, one JSON object per line. Errors are kept as their messages.
+type JSONLinesDeadLetterSink[T any] struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewJSONLinesDeadLetterSink opens, or creates, the file at path for dead letters to be appended to.
+func NewJSONLinesDeadLetterSink[T any](path string) (*JSONLinesDeadLetterSink[T], error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesDeadLetterSink[T]{path: path, file: file}, nil
+}
+
+func (s *JSONLinesDeadLetterSink[T]) Put(_ context.Context, letter DeadLetter[T]) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// DeadLetters reads back every dead letter in the file, including those put before it was opened.
+func (s *JSONLinesDeadLetterSink[T]) DeadLetters() ([]DeadLetter[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// TakeDeadLetters reads back every dead letter in the file, as DeadLetters does, and then empties it.
+func (s *JSONLinesDeadLetterSink[T]) TakeDeadLetters() ([]DeadLetter[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return nil, fmt.Errorf("failed to empty dead letter file: %w", err)
+	}
+	return letters, nil
+}
+
+func (s *JSONLinesDeadLetterSink[T]) read() ([]DeadLetter[T], error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var letters []DeadLetter[T]
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter DeadLetter[T]
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal dead letter on line %d: %w", line, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, scanner.Err()
+}
+
+func (s *JSONLinesDeadLetterSink[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
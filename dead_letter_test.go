@@ -0,0 +1,296 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type attemptsErr struct {
+	err      error
+	attempts int
+}
+
+func (e attemptsErr) Error() string { return e.err.Error() }
+func (e attemptsErr) Unwrap() error { return e.err }
+func (e attemptsErr) Attempts() int { return e.attempts }
+
+type failingSink[T any] struct{ err error }
+
+func (f failingSink[T]) Put(context.Context, DeadLetter[T]) error { return f.err }
+
+func TestWithDeadLetter(t *testing.T) {
+	errOdd := errors.New("odd numbers not allowed")
+	evensOnly := ErrWorkerFunc[int, int](func(ctx context.Context, in int) (int, error) {
+		if in%2 != 0 {
+			return 0, errOdd
+		}
+		return in * 10, nil
+	})
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { WithDeadLetter[int, int](nil, evensOnly) })
+		assert.Panics(t, func() { WithDeadLetter[int, int](NewMemoryDeadLetterSink[int](), nil) })
+	})
+
+	t.Run("when a worker fails on items in FanOut, they should be put in the sink and the error still returned", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+		workerFunc := WithDeadLetter[int, int](sink, evensOnly).Results()
+
+		failures := 0
+		for res := range FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4}), 2, workerFunc)) {
+			if res.Error != nil {
+				assert.ErrorIs(t, res.Error, errOdd)
+				failures++
+			}
+		}
+		assert.Equal(t, 2, failures)
+
+		letters, err := sink.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, letters, 2)
+		assert.ElementsMatch(t, []int{1, 3}, []int{letters[0].Item, letters[1].Item})
+		for _, letter := range letters {
+			assert.ErrorIs(t, letter.Err, errOdd)
+			assert.Equal(t, 1, letter.Attempts)
+			assert.False(t, letter.Time.IsZero())
+		}
+	})
+
+	t.Run("when the error reports its attempts, the dead letter should record them", func(t *testing.T) {
+		sink := NewMemoryDeadLetterSink[int]()
+		workerFunc := WithDeadLetter[int, int](sink, func(ctx context.Context, in int) (int, error) {
+			return 0, attemptsErr{err: errOdd, attempts: 3}
+		})
+
+		_, err := workerFunc(context.Background(), 1)
+		assert.ErrorIs(t, err, errOdd)
+		letters, _ := sink.DeadLetters()
+		assert.Equal(t, 3, letters[0].Attempts)
+	})
+
+	t.Run("when a Clock is given, the dead letter should be timed by it", func(t *testing.T) {
+		clock := newFakeClock()
+		sink := NewMemoryDeadLetterSink[int]()
+		workerFunc := WithDeadLetter[int, int](sink, evensOnly, func(o *DeadLetterOptions) { o.Clock = clock })
+
+		_, _ = workerFunc(context.Background(), 1)
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 1)
+		assert.Equal(t, clock.Now(), letters[0].Time)
+	})
+
+	t.Run("when an item fails once ctx is cancelled, it should not be put in the sink", func(t *testing.T) {
+		sink := NewMemoryDeadLetterSink[int]()
+		workerFunc := WithDeadLetter[int, int](sink, func(ctx context.Context, in int) (int, error) {
+			return 0, ctx.Err()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := workerFunc(ctx, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		letters, _ := sink.DeadLetters()
+		assert.Empty(t, letters)
+	})
+
+	t.Run("when the sink fails, its error should be logged", func(t *testing.T) {
+		logger := newRecordingLogger()
+		workerFunc := WithDeadLetter[int, int](failingSink[int]{errors.New("disk full")}, evensOnly)
+
+		_, err := workerFunc(WithLogger(context.Background(), logger), 1)
+		assert.ErrorIs(t, err, errOdd)
+		assert.Equal(t, []string{"ERROR failed to put dead letter error=disk full cause=odd numbers not allowed"}, logger.lines())
+	})
+}
+
+func TestDeadLetterTo(t *testing.T) {
+	errOdd := errors.New("odd numbers not allowed")
+	evensOnly := ErrWorkerFunc[int, int](func(ctx context.Context, in int) (int, error) {
+		if in%2 != 0 {
+			return 0, errOdd
+		}
+		return in * 10, nil
+	}).Results()
+
+	t.Run("when sink has a nil value, or takes other items than the inStream, we should panic", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Panics(t, func() { DeadLetterTo[int](nil) })
+		assert.Panics(t, func() {
+			FanOut(ctx, make(chan int), 1, evensOnly, DeadLetterTo[string](NewMemoryDeadLetterSink[string]()))
+		})
+		assert.Panics(t, func() {
+			WorkerThread(ctx, make(chan int), evensOnly, DeadLetterTo[string](NewMemoryDeadLetterSink[string]()))
+		})
+	})
+
+	t.Run("when results from FanOut hold errors, their items should be put in the sink", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+
+		failures := 0
+		for res := range FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4}), 2, evensOnly, DeadLetterTo[int](sink))) {
+			if res.Error != nil {
+				failures++
+			}
+		}
+		assert.Equal(t, 2, failures)
+
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 2)
+		assert.ElementsMatch(t, []int{1, 3}, []int{letters[0].Item, letters[1].Item})
+		assert.ErrorIs(t, letters[0].Err, errOdd)
+	})
+
+	t.Run("when an item in WorkerThread times out, it should be put in the sink", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+		release := make(chan struct{})
+		defer close(release)
+		hangs := func(ctx context.Context, in int) int {
+			<-release
+			return in
+		}
+
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []int{1}), hangs, DeadLetterTo[int](sink), func(ops *WorkerOptions) {
+			ops.ItemTimeout = time.Millisecond
+			ops.OnItemTimeout = func(*ItemTimeoutError) {}
+		})
+		expectStreamLengthToBe(0, outStream, t)
+
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 1)
+		var timeoutErr *ItemTimeoutError
+		assert.ErrorAs(t, letters[0].Err, &timeoutErr)
+	})
+}
+
+func TestJSONLinesDeadLetterSink(t *testing.T) {
+	t.Run("when dead letters are put, they should be read back from the file, even when reopened", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+		sink, err := NewJSONLinesDeadLetterSink[sampleObject](path)
+		require.NoError(t, err)
+
+		workerFunc := WithDeadLetter[sampleObject, int](sink, func(ctx context.Context, in sampleObject) (int, error) {
+			return 0, errors.New("rejected")
+		})
+		_, _ = workerFunc(context.Background(), createSampleObject())
+		require.NoError(t, sink.Close())
+
+		reopened, err := NewJSONLinesDeadLetterSink[sampleObject](path)
+		require.NoError(t, err)
+		defer reopened.Close()
+		require.NoError(t, reopened.Put(context.Background(), DeadLetter[sampleObject]{Item: sampleObject{Name: "Jane"}, Attempts: 2}))
+
+		letters, err := reopened.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, letters, 2)
+		assert.Equal(t, createSampleObject(), letters[0].Item)
+		assert.EqualError(t, letters[0].Err, "rejected")
+		assert.Equal(t, "Jane", letters[1].Item.Name)
+		assert.Nil(t, letters[1].Err)
+		assert.Equal(t, 2, letters[1].Attempts)
+	})
+
+	t.Run("when dead letters are taken, the file should be emptied", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+		sink, err := NewJSONLinesDeadLetterSink[int](path)
+		require.NoError(t, err)
+		defer sink.Close()
+		require.NoError(t, sink.Put(context.Background(), DeadLetter[int]{Item: 1}))
+
+		letters, err := sink.TakeDeadLetters()
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		assert.Equal(t, 1, letters[0].Item)
+
+		require.NoError(t, sink.Put(context.Background(), DeadLetter[int]{Item: 2}))
+		letters, err = sink.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		assert.Equal(t, 2, letters[0].Item)
+	})
+
+	t.Run("when the file holds a bad line, we should receive an error naming it", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("{\"item\":1}\nnot json\n"), 0o644))
+		sink, err := NewJSONLinesDeadLetterSink[int](path)
+		require.NoError(t, err)
+		defer sink.Close()
+
+		_, err = sink.DeadLetters()
+		assert.ErrorContains(t, err, "line 2")
+	})
+
+	t.Run("when the file can't be opened, we should receive an error", func(t *testing.T) {
+		_, err := NewJSONLinesDeadLetterSink[int](filepath.Join(t.TempDir(), "missing", "dead-letters.jsonl"))
+		assert.Error(t, err)
+	})
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("when r has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { _, _ = Replay[int](context.Background(), nil) })
+	})
+
+	t.Run("when dead letters are replayed, their items should be fed back through the pipeline", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+		for _, i := range []int{1, 3} {
+			require.NoError(t, sink.Put(ctx, DeadLetter[int]{Item: i, Err: errors.New("flaky")}))
+		}
+
+		items, err := Replay[int](ctx, sink)
+		require.NoError(t, err)
+
+		var res []int
+		for r := range FanIn(ctx, FanOut(ctx, items, 1, func(ctx context.Context, in int) int { return in * 10 })) {
+			res = append(res, r)
+		}
+		assert.Equal(t, []int{10, 30}, res)
+	})
+
+	t.Run("when replayed items fail again, they should be held once", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+		require.NoError(t, sink.Put(ctx, DeadLetter[int]{Item: 1, Err: errors.New("flaky")}))
+
+		items, err := Replay[int](ctx, sink)
+		require.NoError(t, err)
+		stillFlaky := WithDeadLetter[int, int](sink, func(ctx context.Context, in int) (int, error) {
+			return 0, errors.New("flaky")
+		})
+		for res := range FanIn(ctx, FanOut(ctx, items, 1, stillFlaky.Results())) {
+			assert.Error(t, res.Error)
+		}
+
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 1)
+		assert.Equal(t, 1, letters[0].Item)
+	})
+
+	t.Run("when ctx is cancelled before every item is read, those left should be put back", func(t *testing.T) {
+		sink := NewMemoryDeadLetterSink[int]()
+		for _, i := range []int{1, 2, 3} {
+			require.NoError(t, sink.Put(context.Background(), DeadLetter[int]{Item: i}))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		items, err := Replay[int](ctx, sink)
+		require.NoError(t, err)
+		assert.Equal(t, 1, <-items)
+		cancel()
+		expectClosedChannel(true, items, t)
+
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 2)
+		assert.Equal(t, []int{2, 3}, []int{letters[0].Item, letters[1].Item})
+	})
+}
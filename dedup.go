@@ -0,0 +1,151 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type DedupOptions struct {
+	// TTL is how long a result is remembered and handed straight back for the same key. When zero, results are only
+	// shared between calls that overlap.
+	TTL time.Duration
+	// RememberErrors has failed calls remembered for the TTL too. By default only successful results are, so that the
+	// next call for the key tries again.
+	RememberErrors bool
+
+	Clock Clock
+}
+
+type DedupOption func(*DedupOptions)
+
+// Dedup wraps workerFunc so that there is only ever one call in flight for each key, as given by keyFunc. Calls made
+// for a key while it is in flight wait for that call and share its result and error. A waiting call whose ctx is
+// cancelled returns ctx's error straight away.
+//
+// The call in flight runs with the ctx of whichever call started it. Should that ctx be cancelled before the call
+// returns, its result is neither shared nor remembered, as it may be down to the cancellation rather than the key,
+// and the calls waiting on it make their own. To use Dedup with FanOut, pass it the wrapped func's Results.
+func Dedup[In any, K comparable, Out any](keyFunc func(In) K, workerFunc ErrWorkerFunc[In, Out], options ...DedupOption) ErrWorkerFunc[In, Out] {
+	if keyFunc == nil {
+		panic("Dedup: keyFunc arg has nil value")
+	}
+
+	if workerFunc == nil {
+		panic("Dedup: workerFunc arg has nil value")
+	}
+
+	ops := DedupOptions{
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	return newDedup(keyFunc, workerFunc, ops).do
+}
+
+func newDedup[In any, K comparable, Out any](keyFunc func(In) K, workerFunc ErrWorkerFunc[In, Out], ops DedupOptions) *dedup[In, K, Out] {
+	return &dedup[In, K, Out]{keyFunc: keyFunc, workerFunc: workerFunc, ops: ops}
+}
+
+type dedup[In any, K comparable, Out any] struct {
+	keyFunc    func(In) K
+	workerFunc ErrWorkerFunc[In, Out]
+	ops        DedupOptions
+	flights    flightGroup[K, Out]
+
+	mu        sync.Mutex
+	memo      map[K]memoEntry[Out]
+	nextSweep int
+}
+
+type memoEntry[Out any] struct {
+	res     Out
+	err     error
+	expires time.Time
+}
+
+func (d *dedup[In, K, Out]) do(ctx context.Context, in In) (Out, error) {
+	key := d.keyFunc(in)
+	if entry, ok := d.remembered(key); ok {
+		return entry.res, entry.err
+	}
+
+	for {
+		var led bool
+		var ownRes Out
+		var ownErr error
+		res, err := d.flights.do(ctx, key, func() (Out, error) {
+			led = true
+			// a call that finished just before this one started may have left its result behind
+			if entry, ok := d.remembered(key); ok {
+				ownRes, ownErr = entry.res, entry.err
+				return entry.res, entry.err
+			}
+
+			ownRes, ownErr = d.workerFunc(ctx, in)
+			if ctx.Err() != nil {
+				return ownRes, errFlightAbandoned
+			}
+			if ownErr == nil || d.ops.RememberErrors {
+				d.remember(key, ownRes, ownErr)
+			}
+			return ownRes, ownErr
+		})
+
+		switch {
+		case led:
+			return ownRes, ownErr
+		case errors.Is(err, errFlightAbandoned):
+			continue
+		}
+		return res, err
+	}
+}
+
+func (d *dedup[In, K, Out]) remembered(key K) (memoEntry[Out], bool) {
+	if d.ops.TTL <= 0 {
+		return memoEntry[Out]{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.memo[key]
+	if !ok {
+		return entry, false
+	}
+	if !d.ops.Clock.Now().Before(entry.expires) {
+		delete(d.memo, key)
+		return memoEntry[Out]{}, false
+	}
+	return entry, true
+}
+
+func (d *dedup[In, K, Out]) remember(key K, res Out, err error) {
+	if d.ops.TTL <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.ops.Clock.Now()
+	if d.memo == nil {
+		d.memo = make(map[K]memoEntry[Out])
+	}
+	d.memo[key] = memoEntry[Out]{res: res, err: err, expires: now.Add(d.ops.TTL)}
+
+	// keys that are never asked for again would otherwise be held forever, so every so often clear out the expired ones
+	if len(d.memo) >= d.nextSweep {
+		for k, entry := range d.memo {
+			if !now.Before(entry.expires) {
+				delete(d.memo, k)
+			}
+		}
+		d.nextSweep = 2*len(d.memo) + 64
+	}
+}
@@ -0,0 +1,212 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup(t *testing.T) {
+	type lookup struct {
+		Sku     string
+		Attempt int
+	}
+	skuOf := func(l lookup) string { return l.Sku }
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Dedup[lookup, string, int](nil, func(ctx context.Context, in lookup) (int, error) { return 0, nil })
+		})
+		assert.Panics(t, func() { Dedup[lookup, string, int](skuOf, nil) })
+	})
+
+	t.Run("when calls for the same key overlap, the workerFunc should run once and share its result", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		d := newDedup(skuOf, func(ctx context.Context, in lookup) (int, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return in.Attempt, nil
+		}, DedupOptions{Clock: RealClock()})
+
+		var wg sync.WaitGroup
+		results := make([]int, 5)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res, err := d.do(context.Background(), lookup{Sku: "sku-1", Attempt: i})
+				assert.NoError(t, err)
+				results[i] = res
+			}(i)
+		}
+
+		awaitFlightWaiters(t, &d.flights, "sku-1", 4)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		for _, res := range results {
+			assert.Equal(t, results[0], res)
+		}
+	})
+
+	t.Run("when calls for different keys overlap, each key should get its own call", func(t *testing.T) {
+		var calls int32
+		workerFunc := Dedup(skuOf, func(ctx context.Context, in lookup) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			return in.Sku, nil
+		})
+
+		var wg sync.WaitGroup
+		for _, sku := range []string{"sku-1", "sku-2", "sku-3"} {
+			wg.Add(1)
+			go func(sku string) {
+				defer wg.Done()
+				res, err := workerFunc(context.Background(), lookup{Sku: sku})
+				assert.NoError(t, err)
+				assert.Equal(t, sku, res)
+			}(sku)
+		}
+		wg.Wait()
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("when there is no TTL, calls that don't overlap should each run", func(t *testing.T) {
+		var calls int32
+		workerFunc := Dedup(skuOf, func(ctx context.Context, in lookup) (int, error) {
+			return int(atomic.AddInt32(&calls, 1)), nil
+		})
+
+		res, _ := workerFunc(context.Background(), lookup{Sku: "sku-1"})
+		assert.Equal(t, 1, res)
+		res, _ = workerFunc(context.Background(), lookup{Sku: "sku-1"})
+		assert.Equal(t, 2, res)
+	})
+
+	t.Run("when there is a TTL, results should be remembered until it passes", func(t *testing.T) {
+		clock := newFakeClock()
+		var calls int32
+		workerFunc := Dedup(skuOf, func(ctx context.Context, in lookup) (int, error) {
+			return int(atomic.AddInt32(&calls, 1)), nil
+		}, func(o *DedupOptions) {
+			o.TTL = time.Minute
+			o.Clock = clock
+		})
+
+		res, _ := workerFunc(context.Background(), lookup{Sku: "sku-1"})
+		assert.Equal(t, 1, res)
+		clock.Advance(time.Second * 59)
+		res, _ = workerFunc(context.Background(), lookup{Sku: "sku-1"})
+		assert.Equal(t, 1, res)
+		clock.Advance(time.Second)
+		res, _ = workerFunc(context.Background(), lookup{Sku: "sku-1"})
+		assert.Equal(t, 2, res)
+	})
+
+	t.Run("when a call fails, its error should be shared but only remembered if RememberErrors is set", func(t *testing.T) {
+		errNotFound := errors.New("not found")
+		for _, remember := range []bool{false, true} {
+			var calls int32
+			workerFunc := Dedup(skuOf, func(ctx context.Context, in lookup) (int, error) {
+				atomic.AddInt32(&calls, 1)
+				return 0, errNotFound
+			}, func(o *DedupOptions) {
+				o.TTL = time.Minute
+				o.Clock = newFakeClock()
+				o.RememberErrors = remember
+			})
+
+			for i := 0; i < 2; i++ {
+				_, err := workerFunc(context.Background(), lookup{Sku: "sku-1"})
+				assert.ErrorIs(t, err, errNotFound)
+			}
+			if remember {
+				assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+			} else {
+				assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+			}
+		}
+	})
+
+	t.Run("when a waiting call's ctx is cancelled, it should return ctx's error straight away", func(t *testing.T) {
+		release := make(chan struct{})
+		started := make(chan struct{})
+		workerFunc := Dedup(skuOf, func(ctx context.Context, in lookup) (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+
+		leader := make(chan int)
+		go func() {
+			res, _ := workerFunc(context.Background(), lookup{Sku: "sku-1"})
+			leader <- res
+		}()
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := workerFunc(ctx, lookup{Sku: "sku-1"})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		close(release)
+		assert.Equal(t, 1, <-leader)
+	})
+
+	t.Run("when the call in flight's ctx is cancelled, its result should not be shared or remembered", func(t *testing.T) {
+		var calls int32
+		d := newDedup(skuOf, func(ctx context.Context, in lookup) (int, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			return 2, nil
+		}, DedupOptions{TTL: time.Minute, Clock: newFakeClock()})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		leaderErr := make(chan error, 1)
+		go func() {
+			_, err := d.do(ctx, lookup{Sku: "sku-1"})
+			leaderErr <- err
+		}()
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second*5, time.Millisecond)
+
+		waiter := make(chan int, 1)
+		go func() {
+			res, err := d.do(context.Background(), lookup{Sku: "sku-1"})
+			assert.NoError(t, err)
+			waiter <- res
+		}()
+		awaitFlightWaiters(t, &d.flights, "sku-1", 1)
+		cancel()
+
+		assert.ErrorIs(t, <-leaderErr, context.Canceled)
+		assert.Equal(t, 2, <-waiter)
+		res, err := d.do(context.Background(), lookup{Sku: "sku-1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, res)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("when used with FanOut, we should receive a result for every item", func(t *testing.T) {
+		ctx := context.Background()
+		workerFunc := Dedup(skuOf, func(ctx context.Context, in lookup) (string, error) {
+			return in.Sku, nil
+		})
+
+		inStream := GenerateFromSlice(ctx, []lookup{{Sku: "sku-1"}, {Sku: "sku-2"}, {Sku: "sku-1"}})
+		var skus []string
+		for res := range FanIn(ctx, FanOut(ctx, inStream, 1, workerFunc.Results())) {
+			require.NoError(t, res.Error)
+			skus = append(skus, res.Res)
+		}
+		assert.Equal(t, []string{"sku-1", "sku-2", "sku-1"}, skus)
+	})
+}
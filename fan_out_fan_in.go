@@ -1,10 +1,102 @@
 package pipelines
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 type WorkerFunc[Arg, Res any] func(ctx context.Context, in Arg) Res
 
-func FanOut[In, Out any](ctx context.Context, inStream <-chan In, maxProcs int, workerFunc WorkerFunc[In, Out]) <-chan (<-chan Out) {
+// ErrWorkerFunc is a WorkerFunc whose work can fail.
+type ErrWorkerFunc[Arg, Res any] func(ctx context.Context, in Arg) (Res, error)
+
+type Result[T any] struct {
+	Res   T
+	Error error
+}
+
+// Results adapts f into a WorkerFunc, for use with FanOut or WorkerThread, that reports the outcome of each call as a Result.
+func (f ErrWorkerFunc[Arg, Res]) Results() WorkerFunc[Arg, Result[Res]] {
+	if f == nil {
+		panic("ErrWorkerFunc.Results: f has nil value")
+	}
+
+	return func(ctx context.Context, in Arg) Result[Res] {
+		res, err := f(ctx, in)
+		return Result[Res]{Res: res, Error: err}
+	}
+}
+
+// failure lets workers tell a Result that holds an error from any other result, such as for DeadLetterTo.
+func (r Result[T]) failure() error {
+	return r.Error
+}
+
+// failed lets workers make a Result holding err without knowing T, such as for SendItemTimeouts.
+func (r Result[T]) failed(err error) interface{} {
+	return Result[T]{Error: err}
+}
+
+type failedResulter interface {
+	failed(err error) interface{}
+}
+
+type WorkerOptions struct {
+	// ItemTimeout is how long workerFunc has to process each item, through a ctx with that deadline. An item that
+	// takes longer is abandoned without a result, leaving the worker free for the next one, and reported as an
+	// *ItemTimeoutError. Zero means no timeout.
+	ItemTimeout time.Duration
+	// OnItemTimeout is called with each item that times out. By default they are logged to the ctx Logger.
+	OnItemTimeout func(err *ItemTimeoutError)
+	// SendItemTimeouts sends a Result holding the *ItemTimeoutError for each item that times out, rather than no
+	// result at all. It needs the WorkerFunc to return Results, such as those of ErrWorkerFunc.Results.
+	SendItemTimeouts bool
+	// Gate, when set, stops the workers taking items from the inStream while it is shut, such as a paused Valve.
+	Gate Gate
+
+	// deadLetters, set by DeadLetterTo, takes the items that fail.
+	deadLetters deadLetterer
+}
+
+type WorkerOption func(*WorkerOptions)
+
+// ItemTimeoutError reports an item that workerFunc didn't finish within the ItemTimeout.
+type ItemTimeoutError struct {
+	Stage   string
+	Item    interface{}
+	Timeout time.Duration
+}
+
+func (e *ItemTimeoutError) Error() string {
+	return fmt.Sprintf("%s: item timed out after %s", e.Stage, e.Timeout)
+}
+
+func (e *ItemTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+func workerOptions(options []WorkerOption) WorkerOptions {
+	var ops WorkerOptions
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+	return ops
+}
+
+// checkWorkerOptions returns an error if ops can't be used with a WorkerFunc from In to Out.
+func checkWorkerOptions[In, Out any](ops WorkerOptions) error {
+	if err := checkDeadLetters[In](ops); err != nil {
+		return err
+	}
+	var zero Out
+	if _, ok := interface{}(zero).(failedResulter); ops.SendItemTimeouts && !ok {
+		return fmt.Errorf("SendItemTimeouts needs the workerFunc to return Results, not %T", zero)
+	}
+	return nil
+}
+
+func FanOut[In, Out any](ctx context.Context, inStream <-chan In, maxProcs int, workerFunc WorkerFunc[In, Out], options ...WorkerOption) <-chan (<-chan Out) {
 	chanStream := make(chan (<-chan Out))
 	if inStream == nil {
 		close(chanStream)
@@ -16,13 +108,22 @@ func FanOut[In, Out any](ctx context.Context, inStream <-chan In, maxProcs int,
 		panic("FanOut: workerFunc arg has nil value")
 	}
 
+	ops := workerOptions(options)
+	if err := checkWorkerOptions[In, Out](ops); err != nil {
+		close(chanStream)
+		panic(fmt.Sprintf("FanOut: %v", err))
+	}
+	logger := LoggerFromContext(ctx).With("stage", "FanOut")
+
 	go func() {
 		defer close(chanStream)
 		for i := 0; i < maxProcs; i++ {
 			select {
 			case <-ctx.Done():
+				ObserverFromContext(ctx).Cancelled("FanOut")
+				logger.Debug("cancelled before all workers were started", "started", i, "maxProcs", maxProcs)
 				return
-			case chanStream <- WorkerThread(ctx, inStream, workerFunc):
+			case chanStream <- workerThread(ctx, "FanOut", logger.With("worker", i), ops, inStream, workerFunc):
 			}
 		}
 	}()
@@ -30,26 +131,78 @@ func FanOut[In, Out any](ctx context.Context, inStream <-chan In, maxProcs int,
 	return chanStream
 }
 
-func WorkerThread[In, Out any](ctx context.Context, inStream <-chan In, workerFunc WorkerFunc[In, Out]) <-chan Out {
-	resStream := make(chan Out)
+func WorkerThread[In, Out any](ctx context.Context, inStream <-chan In, workerFunc WorkerFunc[In, Out], options ...WorkerOption) <-chan Out {
 	if inStream == nil {
-		close(resStream)
 		panic("WorkerThread: provided stream has nil value")
 	}
+	ops := workerOptions(options)
+	if err := checkWorkerOptions[In, Out](ops); err != nil {
+		panic(fmt.Sprintf("WorkerThread: %v", err))
+	}
+	logger := LoggerFromContext(ctx).With("stage", "WorkerThread")
+	return workerThread(ctx, "WorkerThread", logger, ops, inStream, workerFunc)
+}
+
+// workerThread does the work of WorkerThread, reporting to the ctx Observer under the given stage name.
+func workerThread[In, Out any](ctx context.Context, stage string, logger Logger, ops WorkerOptions, inStream <-chan In, workerFunc WorkerFunc[In, Out]) <-chan Out {
+	resStream := make(chan Out)
+	observer := ObserverFromContext(ctx)
 
 	go func() {
 		defer close(resStream)
+		logger.Debug("worker started")
+		var runner *itemRunner[In, Out]
+		if ops.ItemTimeout > 0 {
+			runner = &itemRunner[In, Out]{stage: stage, workerFunc: workerFunc}
+			defer runner.stop()
+		}
+		var shut <-chan struct{}
 		for {
+			if ops.Gate != nil {
+				if err := ops.Gate.Wait(ctx); err != nil {
+					observer.Cancelled(stage)
+					logger.Debug("worker cancelled", "error", err)
+					return
+				}
+				shut = ops.Gate.Shut()
+			}
+
 			select {
 			case <-ctx.Done():
+				observer.Cancelled(stage)
+				logger.Debug("worker cancelled", "error", ctx.Err())
 				return
+			case <-shut:
 			case item, ok := <-inStream:
 				if !ok {
+					logger.Debug("worker finished, inStream closed")
 					return
 				}
+				observer.ItemIn(stage)
+				// the gate may have shut as the item was read, as select picks at random between ready cases
+				if ops.Gate != nil {
+					if err := ops.Gate.Wait(ctx); err != nil {
+						observer.Cancelled(stage)
+						logger.Debug("worker cancelled, item dropped", "error", err)
+						return
+					}
+				}
+
+				start := time.Now()
+				res, ok := processItem(ctx, stage, logger, ops, runner, item, workerFunc)
+				sendStart := time.Now()
+				observer.WorkerLatency(stage, sendStart.Sub(start))
+				if !ok {
+					continue
+				}
+
 				select {
-				case resStream <- workerFunc(ctx, item):
+				case resStream <- res:
+					observer.BlockedSend(stage, time.Since(sendStart))
+					observer.ItemOut(stage)
 				case <-ctx.Done():
+					observer.Cancelled(stage)
+					logger.Debug("worker cancelled, result dropped", "error", ctx.Err())
 					return
 				}
 			}
@@ -59,6 +212,120 @@ func WorkerThread[In, Out any](ctx context.Context, inStream <-chan In, workerFu
 	return resStream
 }
 
+// processItem runs item through workerFunc as ops say, putting it in the dead letter sink should it fail. ok is false
+// when there is no result to send, such as for an item that timed out.
+func processItem[In, Out any](ctx context.Context, stage string, logger Logger, ops WorkerOptions, runner *itemRunner[In, Out], item In, workerFunc WorkerFunc[In, Out]) (res Out, ok bool) {
+	res, ok = runWorkerWithTimeout(ctx, stage, logger, ops, runner, item, workerFunc)
+	if ok && ops.deadLetters != nil {
+		if f, isResult := any(res).(interface{ failure() error }); isResult && f.failure() != nil {
+			ops.deadLetters.put(ctx, item, f.failure())
+		}
+	}
+	return res, ok
+}
+
+// runWorkerWithTimeout runs item on runner within the ItemTimeout, if there is one, or else on workerFunc directly.
+// Once the item's ctx is done, it has timed out, or ctx has been cancelled, whether or not workerFunc has returned, so
+// that the outcome doesn't hang on a race between the two. Such an item is abandoned, workerFunc being left to finish
+// in the background, and ok is false unless a Result is sent for it under SendItemTimeouts.
+func runWorkerWithTimeout[In, Out any](ctx context.Context, stage string, logger Logger, ops WorkerOptions, runner *itemRunner[In, Out], item In, workerFunc WorkerFunc[In, Out]) (res Out, ok bool) {
+	if runner == nil {
+		return runWorker(ctx, stage, item, workerFunc), true
+	}
+
+	itemCtx, cancel := context.WithTimeout(ctx, ops.ItemTimeout)
+	defer cancel()
+
+	if res, ok := runner.run(itemCtx, item); ok {
+		return res, true
+	}
+	if ctx.Err() != nil {
+		return res, false
+	}
+
+	err := &ItemTimeoutError{Stage: stage, Item: item, Timeout: ops.ItemTimeout}
+	if ops.OnItemTimeout != nil {
+		ops.OnItemTimeout(err)
+	} else {
+		logger.Error("item timed out", "timeout", ops.ItemTimeout)
+	}
+	if ops.SendItemTimeouts {
+		// the Result is dead lettered along with any other that holds an error
+		return interface{}(res).(failedResulter).failed(err).(Out), true
+	}
+	if ops.deadLetters != nil {
+		ops.deadLetters.put(ctx, item, err)
+	}
+	return res, false
+}
+
+// itemRunner runs a worker's items on a goroutine of its own, so that one that times out can be abandoned. The
+// goroutine is kept for the items that follow, and only replaced once one is abandoned, rather than started for
+// every item.
+type itemRunner[In, Out any] struct {
+	stage      string
+	workerFunc WorkerFunc[In, Out]
+	jobs       chan itemJob[In]
+	results    chan Out
+}
+
+type itemJob[In any] struct {
+	ctx  context.Context
+	item In
+}
+
+// run runs item with ctx, returning its result if workerFunc returns before ctx is done.
+func (r *itemRunner[In, Out]) run(ctx context.Context, item In) (res Out, ok bool) {
+	if r.jobs == nil {
+		r.start()
+	}
+	r.jobs <- itemJob[In]{ctx: ctx, item: item}
+
+	select {
+	case res = <-r.results:
+		return res, ctx.Err() == nil
+	case <-ctx.Done():
+		// the goroutine is stuck with the item until workerFunc returns, so leave it to exit once it does
+		r.stop()
+		return res, false
+	}
+}
+
+func (r *itemRunner[In, Out]) start() {
+	jobs := make(chan itemJob[In])
+	// buffered so that an abandoned call can still finish
+	results := make(chan Out, 1)
+	r.jobs, r.results = jobs, results
+
+	go func() {
+		for job := range jobs {
+			results <- runWorker(job.ctx, r.stage, job.item, r.workerFunc)
+		}
+	}()
+}
+
+func (r *itemRunner[In, Out]) stop() {
+	if r.jobs != nil {
+		close(r.jobs)
+		r.jobs = nil
+	}
+}
+
+// runWorker hands item to workerFunc. Items that carry a context of their own, such as an Envelope, are processed
+// with that context, within a span named after the stage.
+func runWorker[In, Out any](ctx context.Context, stage string, item In, workerFunc WorkerFunc[In, Out]) Out {
+	if _, ok := interface{}(item).(itemContextCarrier); !ok {
+		return workerFunc(ctx, item)
+	}
+
+	itemCtx, release := withItemContext(ctx, item)
+	defer release()
+	itemCtx, finish := StartSpan(itemCtx, stage)
+	defer finish(nil)
+
+	return workerFunc(itemCtx, item)
+}
+
 func FanIn[T any](ctx context.Context, chanStream <-chan (<-chan T)) chan T {
 	outStream := make(chan T)
 	if chanStream == nil {
@@ -66,6 +333,8 @@ func FanIn[T any](ctx context.Context, chanStream <-chan (<-chan T)) chan T {
 		panic("FanIn: chanStream has nil value")
 	}
 
+	observer := ObserverFromContext(ctx)
+
 	go func() {
 		defer close(outStream)
 		for {
@@ -77,12 +346,18 @@ func FanIn[T any](ctx context.Context, chanStream <-chan (<-chan T)) chan T {
 				}
 				possStream = chn
 			case <-ctx.Done():
+				observer.Cancelled("FanIn")
 				return
 			}
 			for t := range OrDone(ctx, possStream) {
+				observer.ItemIn("FanIn")
+				sendStart := time.Now()
 				select {
 				case outStream <- t:
+					observer.BlockedSend("FanIn", time.Since(sendStart))
+					observer.ItemOut("FanIn")
 				case <-ctx.Done():
+					observer.Cancelled("FanIn")
 					return
 				}
 			}
@@ -2,7 +2,10 @@ package pipelines
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFanOut(t *testing.T) {
@@ -194,3 +197,138 @@ func TestFanIn(t *testing.T) {
 		expectClosedChannel(true, outStream, t)
 	})
 }
+
+func TestErrWorkerFuncResults(t *testing.T) {
+	errOdd := errors.New("odd")
+	var evensOnly ErrWorkerFunc[int, int] = func(ctx context.Context, in int) (int, error) {
+		if in%2 != 0 {
+			return 0, errOdd
+		}
+		return in, nil
+	}
+
+	t.Run("when f has a nil value, we should receive a panic", func(t *testing.T) {
+		defer func() {
+			if perr := recover(); perr == nil {
+				t.Errorf("expected Results to panic but got %v", perr)
+			}
+		}()
+		_ = ErrWorkerFunc[int, int](nil).Results()
+	})
+
+	t.Run("when we pass the adapted func to a WorkerThread, we should receive each outcome as a Result", func(t *testing.T) {
+		ctx := context.Background()
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []int{1, 2}), evensOnly.Results())
+		expectOrderedResultsList([]Result[int]{{Error: errOdd}, {Res: 2}}, outStream, t)
+	})
+}
+
+func TestWorkerItemTimeout(t *testing.T) {
+	// hangsOnZero never returns for 0, as a stuck call might, until the test ends
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	var hangsOnZero WorkerFunc[int, int] = func(ctx context.Context, in int) int {
+		if in == 0 {
+			<-release
+		}
+		return in * 10
+	}
+
+	t.Run("when an item hangs, it should time out and the worker should carry on with the next", func(t *testing.T) {
+		ctx := context.Background()
+		timedOut := make(chan *ItemTimeoutError, 1)
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []int{1, 0, 2}), hangsOnZero, func(ops *WorkerOptions) {
+			ops.ItemTimeout = time.Millisecond * 20
+			ops.OnItemTimeout = func(err *ItemTimeoutError) { timedOut <- err }
+		})
+		expectOrderedResultsList([]int{10, 20}, outStream, t)
+
+		err := <-timedOut
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the error to be a context.DeadlineExceeded but got %v", err)
+		}
+		if err.Item != 0 || err.Stage != "WorkerThread" {
+			t.Errorf("expected the error to name item 0 in WorkerThread but got %+v", err)
+		}
+		if err.Error() != "WorkerThread: item timed out after 20ms" {
+			t.Errorf("unexpected error message %q", err.Error())
+		}
+	})
+
+	t.Run("when a workerFunc honours its ctx, it should receive the item deadline and its item should time out", func(t *testing.T) {
+		ctx := context.Background()
+		hadDeadline := make(chan bool, 1)
+		var waitForDeadline WorkerFunc[int, int] = func(ctx context.Context, in int) int {
+			_, ok := ctx.Deadline()
+			hadDeadline <- ok
+			<-ctx.Done()
+			return in
+		}
+		timedOut := make(chan *ItemTimeoutError, 1)
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []int{1}), waitForDeadline, func(ops *WorkerOptions) {
+			ops.ItemTimeout = time.Millisecond * 10
+			ops.OnItemTimeout = func(err *ItemTimeoutError) { timedOut <- err }
+		})
+
+		// it returns as soon as its ctx is done, but by then the item has timed out, so there should never be a result
+		expectStreamLengthToBe(0, outStream, t)
+		if !<-hadDeadline {
+			t.Errorf("expected the worker ctx to have a deadline")
+		}
+		if err := <-timedOut; err.Item != 1 {
+			t.Errorf("expected item 1 to time out but got %+v", err)
+		}
+	})
+
+	t.Run("when SendItemTimeouts is set, timed out items should be sent as Results holding the error", func(t *testing.T) {
+		ctx := context.Background()
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []int{1, 0, 2}), ErrWorkerFunc[int, int](func(ctx context.Context, in int) (int, error) {
+			return hangsOnZero(ctx, in), nil
+		}).Results(), func(ops *WorkerOptions) {
+			ops.ItemTimeout = time.Millisecond * 20
+			ops.OnItemTimeout = func(*ItemTimeoutError) {}
+			ops.SendItemTimeouts = true
+		})
+
+		var results []Result[int]
+		for res := range outStream {
+			results = append(results, res)
+		}
+		if len(results) != 3 || results[0].Res != 10 || results[2].Res != 20 {
+			t.Fatalf("expected results for all 3 items but got %+v", results)
+		}
+		var timeoutErr *ItemTimeoutError
+		if !errors.As(results[1].Error, &timeoutErr) || timeoutErr.Item != 0 {
+			t.Errorf("expected the second result to hold the item's *ItemTimeoutError but got %v", results[1].Error)
+		}
+	})
+
+	t.Run("when SendItemTimeouts is set but the workerFunc doesn't return Results, we should panic", func(t *testing.T) {
+		ctx := context.Background()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected a panic")
+			}
+		}()
+		WorkerThread(ctx, make(chan int), hangsOnZero, func(ops *WorkerOptions) { ops.SendItemTimeouts = true })
+	})
+
+	t.Run("when there is no OnItemTimeout, timed out items should be logged", func(t *testing.T) {
+		logger := newRecordingLogger()
+		ctx := WithLogger(context.Background(), logger)
+		outStream := FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, []int{0, 3}), 1, hangsOnZero, func(ops *WorkerOptions) {
+			ops.ItemTimeout = time.Millisecond * 20
+		}))
+		expectOrderedResultsList([]int{30}, outStream, t)
+
+		found := false
+		for _, line := range logger.lines() {
+			if strings.HasPrefix(line, "ERROR item timed out") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected the timed out item to be logged but got %v", logger.lines())
+		}
+	})
+}
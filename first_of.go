@@ -0,0 +1,46 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+)
+
+// FirstOf returns an ErrWorkerFunc that runs every one of workerFuncs concurrently, returning the first successful
+// result and cancelling the rest. If all of them fail, their errors are returned joined together.
+func FirstOf[In, Out any](workerFuncs ...ErrWorkerFunc[In, Out]) ErrWorkerFunc[In, Out] {
+	if len(workerFuncs) == 0 {
+		panic("FirstOf: no workerFuncs provided")
+	}
+
+	for _, workerFunc := range workerFuncs {
+		if workerFunc == nil {
+			panic("FirstOf: workerFunc arg has nil value")
+		}
+	}
+
+	return func(ctx context.Context, in In) (Out, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// buffered so that the losers can finish without anyone waiting on them
+		results := make(chan Result[Out], len(workerFuncs))
+		for _, workerFunc := range workerFuncs {
+			go func(workerFunc ErrWorkerFunc[In, Out]) {
+				res, err := workerFunc(ctx, in)
+				results <- Result[Out]{Res: res, Error: err}
+			}(workerFunc)
+		}
+
+		errs := make([]error, 0, len(workerFuncs))
+		for range workerFuncs {
+			r := <-results
+			if r.Error == nil {
+				return r.Res, nil
+			}
+			errs = append(errs, r.Error)
+		}
+
+		var out Out
+		return out, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,56 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstOf(t *testing.T) {
+	t.Run("when no workerFuncs are provided, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { FirstOf[int, int]() })
+	})
+
+	t.Run("when one of the workerFuncs has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			FirstOf[int, int](func(ctx context.Context, in int) (int, error) { return in, nil }, nil)
+		})
+	})
+
+	t.Run("when one workerFunc answers first, we should receive its result and the others should be cancelled", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		slow := func(ctx context.Context, in int) (int, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return 0, ctx.Err()
+		}
+		fast := func(ctx context.Context, in int) (int, error) { return in * 2, nil }
+
+		res, err := FirstOf[int, int](slow, fast)(context.Background(), 21)
+		assert.NoError(t, err)
+		assert.Equal(t, 42, res)
+		<-cancelled
+	})
+
+	t.Run("when a workerFunc fails, we should wait for one that succeeds", func(t *testing.T) {
+		release := make(chan struct{})
+		failing := func(ctx context.Context, in int) (int, error) { defer close(release); return 0, errors.New("failed") }
+		succeeding := func(ctx context.Context, in int) (int, error) { <-release; return in, nil }
+
+		res, err := FirstOf[int, int](failing, succeeding)(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, res)
+	})
+
+	t.Run("when every workerFunc fails, we should receive all of their errors", func(t *testing.T) {
+		errA, errB := errors.New("a"), errors.New("b")
+		_, err := FirstOf[int, int](
+			func(ctx context.Context, in int) (int, error) { return 0, errA },
+			func(ctx context.Context, in int) (int, error) { return 0, errB },
+		)(context.Background(), 1)
+		assert.ErrorIs(t, err, errA)
+		assert.ErrorIs(t, err, errB)
+	})
+}
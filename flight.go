@@ -0,0 +1,72 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errFlightPanicked = errors.New("call in flight panicked")
+
+// errFlightAbandoned is returned by a call in flight to tell those waiting on it that it was cancelled by the caller
+// making it, and that they should make their own.
+var errFlightAbandoned = errors.New("call in flight was cancelled")
+
+// flightGroup coalesces concurrent calls that share a key into one, whose result every caller receives.
+type flightGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flightCall[V]
+}
+
+type flightCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+	// waiters is the number of callers that have joined the call, besides the one making it. It is guarded by the
+	// group's mu.
+	waiters int
+}
+
+// do calls fn, unless a call for key is already in flight, in which case it waits for that call's result instead. A
+// waiting caller gives up when ctx is done, while the call in flight carries on for the others.
+func (g *flightGroup[K, V]) do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*flightCall[V])
+	}
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			var v V
+			return v, ctx.Err()
+		}
+	}
+
+	c := &flightCall[V]{done: make(chan struct{}), err: errFlightPanicked}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+// waiting returns the number of callers waiting on the call in flight for key.
+func (g *flightGroup[K, V]) waiting(key K) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok {
+		return c.waiters
+	}
+	return 0
+}
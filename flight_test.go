@@ -0,0 +1,85 @@
+package pipelines
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// awaitFlightWaiters blocks until n callers are waiting on the call in flight for key, so that a test can be sure
+// they've all joined it before letting it finish.
+func awaitFlightWaiters[K comparable, V any](t *testing.T, g *flightGroup[K, V], key K, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return g.waiting(key) >= n }, time.Second*5, time.Millisecond)
+}
+
+func TestFlightGroup(t *testing.T) {
+	t.Run("when calls for the same key overlap, fn should run once and every caller get its result", func(t *testing.T) {
+		var g flightGroup[string, int]
+		var calls int32
+		release := make(chan struct{})
+
+		var wg sync.WaitGroup
+		results := make([]int, 5)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = g.do(context.Background(), "key", func() (int, error) {
+					atomic.AddInt32(&calls, 1)
+					<-release
+					return 42, nil
+				})
+			}(i)
+		}
+
+		awaitFlightWaiters(t, &g, "key", 4)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		assert.Equal(t, []int{42, 42, 42, 42, 42}, results)
+	})
+
+	t.Run("when a waiting caller's ctx is cancelled, it should give up without stopping the call", func(t *testing.T) {
+		var g flightGroup[string, int]
+		release := make(chan struct{})
+		leader := make(chan int)
+		go func() {
+			v, _ := g.do(context.Background(), "key", func() (int, error) {
+				<-release
+				return 1, nil
+			})
+			leader <- v
+		}()
+
+		assert.Eventually(t, func() bool {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return len(g.calls) == 1
+		}, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := g.do(ctx, "key", func() (int, error) { return 2, nil })
+		assert.ErrorIs(t, err, context.Canceled)
+
+		close(release)
+		assert.Equal(t, 1, <-leader)
+	})
+
+	t.Run("when fn panics, waiting callers should receive an error", func(t *testing.T) {
+		var g flightGroup[string, int]
+		assert.Panics(t, func() {
+			_, _ = g.do(context.Background(), "key", func() (int, error) { panic("boom") })
+		})
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		assert.Empty(t, g.calls)
+	})
+}
@@ -3,13 +3,10 @@ package pipelines
 import (
 	"context"
 	"errors"
-	"os"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
-func doWorkWithHeartbeats[R any](ctx context.Context, pulseInterval time.Duration, task func(context.Context) R, logger HeartbeatsLogger) (<-chan interface{}, <-chan R) {
+func doWorkWithHeartbeats[R any](ctx context.Context, pulseInterval time.Duration, task func(context.Context) R, logger Logger) (<-chan interface{}, <-chan R) {
 	if task == nil {
 		panic("doWorkWithHeartbeats: task arg has nil value")
 	}
@@ -41,7 +38,7 @@ func doWorkWithHeartbeats[R any](ctx context.Context, pulseInterval time.Duratio
 		sendPulse := func() {
 			select {
 			case heartbeat <- struct{}{}:
-				logger.Debugf("doWorkWithHeartbeats: pulse\n")
+				logger.Debug("pulse")
 			default:
 			}
 		}
@@ -69,7 +66,7 @@ func doWorkWithHeartbeats[R any](ctx context.Context, pulseInterval time.Duratio
 				if !ok {
 					return
 				}
-				logger.Infof("doWorkWithHeartbeats: task completed")
+				logger.Info("task completed")
 				sendResult(res)
 			}
 		}
@@ -78,6 +75,9 @@ func doWorkWithHeartbeats[R any](ctx context.Context, pulseInterval time.Duratio
 	return heartbeat, results
 }
 
+// HeartbeatsLogger is the printf style logger that DoWorkWithHeartbeats originally accepted.
+//
+// Deprecated: set HeartbeatsOptions.StructuredLogger, or attach a Logger to the context with WithLogger, instead.
 type HeartbeatsLogger interface {
 	Debugf(fmt string, fields ...interface{})
 	Infof(fmt string, fields ...interface{})
@@ -88,42 +88,62 @@ type HeartbeatsOptions struct {
 	PulseInterval time.Duration
 	Timeout       time.Duration
 
+	// Logger, when set, takes precedence over StructuredLogger.
+	//
+	// Deprecated: use StructuredLogger.
 	Logger HeartbeatsLogger
-	Debug  bool
+	// StructuredLogger defaults to the Logger attached to ctx.
+	StructuredLogger Logger
+	// TaskName identifies the task in everything that is logged about it.
+	TaskName string
+	Debug    bool
 }
 
 type HeartbeatsOption func(*HeartbeatsOptions)
 
 func DoWorkWithHeartbeats[R any](ctx context.Context, task func(ctx context.Context) R, options ...HeartbeatsOption) (R, error) {
-	l := logrus.New()
-	l.SetOutput(os.Stdout)
 	ops := HeartbeatsOptions{
-		PulseInterval: time.Second,
-		Timeout:       time.Second * 30,
-		Logger:        l,
+		PulseInterval:    time.Second,
+		Timeout:          time.Second * 30,
+		StructuredLogger: LoggerFromContext(ctx),
 	}
 
 	for _, optFunc := range options {
 		optFunc(&ops)
 	}
 
+	logger := ops.StructuredLogger
+	if ops.Logger != nil {
+		logger = printfLogger{l: ops.Logger}
+	}
+	if logger == nil {
+		logger = NopLogger()
+	}
+	logger = logger.With("stage", "DoWorkWithHeartbeats")
+	if ops.TaskName != "" {
+		logger = logger.With("task", ops.TaskName)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	time.AfterFunc(ops.Timeout, func() { cancel() })
 
-	heartbeat, results := doWorkWithHeartbeats(ctx, ops.PulseInterval, task, ops.Logger)
+	heartbeat, results := doWorkWithHeartbeats(ctx, ops.PulseInterval, task, logger)
 	var r R
 	for {
 		select {
 		case _, ok := <-heartbeat:
 			if !ok {
+				logger.Error("heartbeat channel closed before a result was received")
 				return r, errors.New("DoWorkWithHeartbeats: heartbeat channel may be closed already")
 			}
 		case res, ok := <-results:
 			if !ok {
+				logger.Error("results channel closed before a result was received")
 				return r, errors.New("DoWorkWithHeartbeats: results channel may be closed already")
 			}
 			return res, nil
 		case <-time.After(ops.PulseInterval * 2):
+			logger.Error("task timed out", "pulseInterval", ops.PulseInterval)
 			return r, errors.New("DoWorkWithHeartbeats: task timed out")
 		}
 	}
@@ -10,7 +10,7 @@ import (
 )
 
 func Test_doWorkWithHeartbeats(t *testing.T) {
-	logger := logrus.New()
+	logger := NewLogrusLogger(logrus.New())
 	t.Run("when the longRunningFunc has a nil value, we should panic", func(t *testing.T) {
 		assert.Panics(t, func() {
 			doWorkWithHeartbeats[any](context.TODO(), time.Second, nil, logger)
@@ -0,0 +1,472 @@
+package pipelines
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the size of the LRUCache used by NewCachingClient when no Cache is given.
+const DefaultCacheSize int64 = 64 << 20
+
+// CachedResponse is a response held by a Cache.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Stored is when the response was received, or last revalidated.
+	Stored time.Time
+	// Expires is when the response goes stale and has to be revalidated before it is used again.
+	Expires time.Time
+	// Vary holds the values of the request headers named by the response's Vary header, which a request must match
+	// for the response to be used.
+	Vary http.Header
+}
+
+// Cache stores responses for NewCachingClient. Responses it holds are shared and must not be modified.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse)
+	Delete(key string)
+}
+
+// LRUCache is an in-memory Cache that evicts the least recently used responses once their total size exceeds its limit.
+type LRUCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	res  *CachedResponse
+	size int64
+}
+
+// NewLRUCache returns a LRUCache that holds no more than maxBytes of responses, counting their bodies and headers.
+func NewLRUCache(maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).res, true
+}
+
+// Set stores res under key, unless it is larger than the whole cache.
+func (c *LRUCache) Set(key string, res *CachedResponse) {
+	size := int64(len(key)) + responseSize(res)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	if size > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, res: res, size: size})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns the number of responses held.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*lruEntry)
+	delete(c.items, entry.key)
+	c.size -= entry.size
+}
+
+func responseSize(res *CachedResponse) int64 {
+	size := int64(len(res.Body))
+	for _, h := range []http.Header{res.Header, res.Vary} {
+		for k, values := range h {
+			size += int64(len(k))
+			for _, v := range values {
+				size += int64(len(v))
+			}
+		}
+	}
+	return size
+}
+
+type HttpCacheOptions struct {
+	// Cache defaults to a LRUCache of DefaultCacheSize.
+	Cache Cache
+	// MaxBodySize is the largest response body that is cached, or shared between coalesced requests. Larger responses
+	// are passed straight through.
+	MaxBodySize int64
+
+	Clock Clock
+}
+
+type HttpCacheOption func(*HttpCacheOptions)
+
+// errNotShareable is the result of a coalesced request whose response was too large to share.
+var errNotShareable = errors.New("response too large to share")
+
+// NewCachingClient wraps httpClient with a private cache for GET requests. Responses are kept for as long as their
+// Cache-Control max-age or Expires header allows, and once stale are revalidated with If-None-Match or
+// If-Modified-Since when they have an ETag or Last-Modified header. Responses marked no-store are never kept.
+//
+// Concurrent GET requests for the same URL, with the same Authorization, Cookie and Accept headers, are coalesced into
+// a single upstream request, so FanOut workers asking for the same resource at once cost one call between them. Should
+// the request making that call be cancelled, the others make their own. Responses are only served to requests with
+// the same Authorization and Cookie headers as the one they were stored for.
+// Requests that are already conditional, or ask for a Range, are passed straight through.
+func NewCachingClient(httpClient HttpClient, options ...HttpCacheOption) HttpClient {
+	if httpClient == nil {
+		panic("NewCachingClient: httpClient arg has nil value")
+	}
+
+	ops := HttpCacheOptions{
+		MaxBodySize: DefaultMaxBodySize,
+		Clock:       RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	if ops.Cache == nil {
+		ops.Cache = NewLRUCache(DefaultCacheSize)
+	}
+
+	return &cachingClient{next: httpClient, ops: ops}
+}
+
+// Caching is the Middleware form of NewCachingClient.
+func Caching(options ...HttpCacheOption) Middleware {
+	return func(next HttpClient) HttpClient {
+		return NewCachingClient(next, options...)
+	}
+}
+
+type cachingClient struct {
+	next    HttpClient
+	ops     HttpCacheOptions
+	flights flightGroup[string, *CachedResponse]
+}
+
+// flightHeaders are the request headers that must match for two requests to be coalesced.
+var flightHeaders = []string{"Authorization", "Cookie", "Accept", "Accept-Encoding", "Accept-Language"}
+
+func (c *cachingClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return c.next.Do(req)
+	}
+	reqDirectives := parseCacheControl(req.Header)
+	if _, ok := reqDirectives["no-store"]; ok {
+		return c.next.Do(req)
+	}
+
+	key := cacheKey(req)
+	if stored := c.lookup(key, req); stored != nil && c.fresh(stored, reqDirectives) {
+		return stored.response(req), nil
+	}
+
+	var own *http.Response
+	for {
+		var led bool
+		var leaderErr error
+		entry, err := c.flights.do(req.Context(), flightKey(key, req), func() (*CachedResponse, error) {
+			led = true
+			// a request that finished just before this one started may have refreshed the cache
+			stored := c.lookup(key, req)
+			if stored != nil && c.fresh(stored, reqDirectives) {
+				return stored, nil
+			}
+
+			entry, res, err := c.fetch(key, req, stored)
+			own = res
+			if err != nil && req.Context().Err() != nil {
+				// the upstream call failed because this request was cancelled, which says nothing about the requests
+				// waiting on it, so they're told to make their own
+				leaderErr = err
+				return nil, errFlightAbandoned
+			}
+			return entry, err
+		})
+
+		switch {
+		case led && leaderErr != nil:
+			return nil, leaderErr
+		case errors.Is(err, errFlightAbandoned):
+			continue
+		case own != nil:
+			return own, nil
+		case errors.Is(err, errNotShareable):
+			return c.next.Do(req)
+		case err != nil:
+			return nil, err
+		}
+		return entry.response(req), nil
+	}
+}
+
+func (c *cachingClient) lookup(key string, req *http.Request) *CachedResponse {
+	stored, ok := c.ops.Cache.Get(key)
+	if !ok || !stored.matches(req) {
+		return nil
+	}
+	return stored
+}
+
+func (c *cachingClient) fresh(stored *CachedResponse, reqDirectives map[string]string) bool {
+	now := c.ops.Clock.Now()
+	if _, ok := reqDirectives["no-cache"]; ok {
+		return false
+	}
+	if v, ok := reqDirectives["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err != nil || now.Sub(stored.Stored) > time.Duration(secs)*time.Second {
+			return false
+		}
+	}
+	return now.Before(stored.Expires)
+}
+
+// fetch sends req upstream, revalidating stored if there is one. A response too large to share is returned as is,
+// along with errNotShareable.
+func (c *cachingClient) fetch(key string, req *http.Request, stored *CachedResponse) (*CachedResponse, *http.Response, error) {
+	out := req.Clone(req.Context())
+	if stored != nil {
+		if etag := stored.Header.Get("ETag"); etag != "" {
+			out.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := stored.Header.Get("Last-Modified"); lastModified != "" {
+			out.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	res, err := c.next.Do(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := c.ops.Clock.Now()
+
+	if stored != nil && res.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		entry := stored.revalidated(res.Header, now)
+		c.ops.Cache.Set(key, entry)
+		return entry, nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, c.ops.MaxBodySize+1))
+	if err != nil {
+		res.Body.Close()
+		return nil, nil, err
+	}
+	if int64(len(body)) > c.ops.MaxBodySize {
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+		return nil, res, errNotShareable
+	}
+	res.Body.Close()
+
+	entry := &CachedResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       body,
+		Stored:     now,
+		Expires:    now.Add(freshnessLifetime(res.Header, now)),
+		Vary:       varied(req, res.Header),
+	}
+	if cacheable(res, now) {
+		c.ops.Cache.Set(key, entry)
+	} else if res.StatusCode == http.StatusOK {
+		c.ops.Cache.Delete(key)
+	}
+	return entry, nil, nil
+}
+
+func cacheKey(req *http.Request) string {
+	key := req.Method + " " + req.URL.String()
+	// responses to requests carrying credentials are only shared with requests carrying the same ones
+	auth, cookie := req.Header.Get("Authorization"), strings.Join(req.Header.Values("Cookie"), "; ")
+	if auth != "" || cookie != "" {
+		sum := sha256.Sum256([]byte(auth + "\n" + cookie))
+		key += " " + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
+
+func flightKey(key string, req *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, h := range flightHeaders {
+		sb.WriteString("\n" + strings.Join(req.Header.Values(h), ","))
+	}
+	return sb.String()
+}
+
+func cacheable(res *http.Response, now time.Time) bool {
+	if res.StatusCode != http.StatusOK {
+		return false
+	}
+	if _, ok := parseCacheControl(res.Header)["no-store"]; ok {
+		return false
+	}
+	for _, name := range varyNames(res.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	return freshnessLifetime(res.Header, now) > 0 || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+}
+
+// freshnessLifetime is how long a response stays fresh after it is received, going by its Cache-Control max-age, less
+// its Age, or else its Expires header.
+func freshnessLifetime(h http.Header, now time.Time) time.Duration {
+	directives := parseCacheControl(h)
+	if _, ok := directives["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := directives["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		age, _ := strconv.Atoi(h.Get("Age"))
+		return time.Duration(secs-age) * time.Second
+	}
+	if expires := h.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = now
+		}
+		return t.Sub(date)
+	}
+	return 0
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func varied(req *http.Request, resHeader http.Header) http.Header {
+	names := varyNames(resHeader)
+	if len(names) == 0 {
+		return nil
+	}
+	vary := make(http.Header, len(names))
+	for _, name := range names {
+		vary[name] = append([]string(nil), req.Header.Values(name)...)
+	}
+	return vary
+}
+
+func (c *CachedResponse) matches(req *http.Request) bool {
+	for name, values := range c.Vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidated returns a copy of c brought up to date by the headers of a 304 Not Modified response.
+func (c *CachedResponse) revalidated(h http.Header, now time.Time) *CachedResponse {
+	header := c.Header.Clone()
+	for k, v := range h {
+		if k == "Content-Length" {
+			continue
+		}
+		header[k] = v
+	}
+	return &CachedResponse{
+		StatusCode: c.StatusCode,
+		Header:     header,
+		Body:       c.Body,
+		Stored:     now,
+		Expires:    now.Add(freshnessLifetime(header, now)),
+		Vary:       c.Vary,
+	}
+}
+
+func (c *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode)),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
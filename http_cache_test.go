@@ -0,0 +1,291 @@
+package pipelines
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCachingClient(t *testing.T) {
+	type upstream struct {
+		calls   int32
+		headers chan http.Header
+	}
+
+	newUpstream := func(handle func(r *http.Request) (int, http.Header, string)) (*upstream, HttpClient) {
+		u := &upstream{headers: make(chan http.Header, 10)}
+		return u, HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&u.calls, 1)
+			u.headers <- r.Header.Clone()
+			code, header, body := handle(r)
+			return &http.Response{StatusCode: code, Header: header, Body: io.NopCloser(strings.NewReader(body))}, nil
+		})
+	}
+
+	get := func(t *testing.T, client HttpClient, header http.Header) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/products/1", nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(body)
+	}
+
+	t.Run("when httpClient has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewCachingClient(nil) })
+	})
+
+	t.Run("when a response is fresh, it should be served from the cache until it expires", func(t *testing.T) {
+		clock := newFakeClock()
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "widget"
+		})
+		client := NewCachingClient(next, func(o *HttpCacheOptions) { o.Clock = clock })
+
+		for i := 0; i < 3; i++ {
+			code, body := get(t, client, nil)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "widget", body)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&u.calls))
+
+		clock.Advance(time.Second * 61)
+		_, body := get(t, client, nil)
+		assert.Equal(t, "widget", body)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+	})
+
+	t.Run("when a response is marked no-store, it should never be cached", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"no-store, max-age=60"}}, "widget"
+		})
+		client := NewCachingClient(next)
+
+		get(t, client, nil)
+		get(t, client, nil)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+	})
+
+	t.Run("when a stale response has an ETag, it should be revalidated and reused on a 304", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				return http.StatusNotModified, http.Header{"Cache-Control": {"no-cache"}}, ""
+			}
+			return http.StatusOK, http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}}, "widget"
+		})
+		client := NewCachingClient(next)
+
+		get(t, client, nil)
+		code, body := get(t, client, nil)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "widget", body)
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+		assert.Empty(t, (<-u.headers).Get("If-None-Match"))
+		assert.Equal(t, `"v1"`, (<-u.headers).Get("If-None-Match"))
+	})
+
+	t.Run("when a stale response has a Last-Modified header, it should be revalidated with If-Modified-Since", func(t *testing.T) {
+		lastModified := "Mon, 02 Jan 2006 15:04:05 GMT"
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				return http.StatusNotModified, http.Header{}, ""
+			}
+			return http.StatusOK, http.Header{"Last-Modified": {lastModified}}, "widget"
+		})
+		client := NewCachingClient(next)
+
+		get(t, client, nil)
+		_, body := get(t, client, nil)
+		assert.Equal(t, "widget", body)
+		<-u.headers
+		assert.Equal(t, lastModified, (<-u.headers).Get("If-Modified-Since"))
+	})
+
+	t.Run("when a response varies on a header, requests with other values should not be served it", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}}, r.Header.Get("Accept-Language")
+		})
+		client := NewCachingClient(next)
+
+		_, body := get(t, client, http.Header{"Accept-Language": {"en"}})
+		assert.Equal(t, "en", body)
+		_, body = get(t, client, http.Header{"Accept-Language": {"de"}})
+		assert.Equal(t, "de", body)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+	})
+
+	t.Run("when requests are not GETs, they should pass straight through", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "created"
+		})
+		client := NewCachingClient(next)
+
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequest(http.MethodPost, "https://my-api/products", strings.NewReader("{}"))
+			require.NoError(t, err)
+			_, err = client.Do(req)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+	})
+
+	t.Run("when identical requests overlap, they should share one upstream call", func(t *testing.T) {
+		release := make(chan struct{})
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			<-release
+			return http.StatusOK, http.Header{"Cache-Control": {"no-store"}}, "widget"
+		})
+		client := NewCachingClient(next)
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/products/1", nil)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		bodies := make([]string, 5)
+		for i := range bodies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, bodies[i] = get(t, client, nil)
+			}(i)
+		}
+		<-u.headers
+		awaitFlightWaiters(t, &client.(*cachingClient).flights, flightKey(cacheKey(req), req), 4)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&u.calls))
+		assert.Equal(t, []string{"widget", "widget", "widget", "widget", "widget"}, bodies)
+	})
+
+	t.Run("when the request making a shared upstream call is cancelled, the others should make their own", func(t *testing.T) {
+		var calls int32
+		next := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("widget"))}, nil
+		})
+		client := NewCachingClient(next)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://my-api/products/1", nil)
+		require.NoError(t, err)
+		leaderErr := make(chan error, 1)
+		go func() {
+			_, err := client.Do(req)
+			leaderErr <- err
+		}()
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second*5, time.Millisecond)
+
+		body := make(chan string, 1)
+		go func() {
+			_, b := get(t, client, nil)
+			body <- b
+		}()
+		awaitFlightWaiters(t, &client.(*cachingClient).flights, flightKey(cacheKey(req), req), 1)
+		cancel()
+
+		assert.ErrorIs(t, <-leaderErr, context.Canceled)
+		assert.Equal(t, "widget", <-body)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("when requests carry different cookies, they should not be served each other's responses", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "basket of " + r.Header.Get("Cookie")
+		})
+		client := NewCachingClient(next)
+
+		_, body := get(t, client, http.Header{"Cookie": {"session=jane"}})
+		assert.Equal(t, "basket of session=jane", body)
+		_, body = get(t, client, http.Header{"Cookie": {"session=john"}})
+		assert.Equal(t, "basket of session=john", body)
+		_, body = get(t, client, http.Header{"Cookie": {"session=jane"}})
+		assert.Equal(t, "basket of session=jane", body)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+	})
+
+	t.Run("when a body is larger than MaxBodySize, it should be returned whole but not cached", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "0123456789"
+		})
+		client := NewCachingClient(next, func(o *HttpCacheOptions) { o.MaxBodySize = 4 })
+
+		_, body := get(t, client, nil)
+		assert.Equal(t, "0123456789", body)
+		get(t, client, nil)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&u.calls))
+	})
+
+	t.Run("when used as middleware with HttpReqAsync, we should receive the cached object", func(t *testing.T) {
+		u, next := newUpstream(func(r *http.Request) (int, http.Header, string) {
+			return http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, `{"_id":1,"name":"John","age":30}`
+		})
+		client := Chain(next, Caching())
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			res := <-HttpReqAsync(context.Background(), client, req, JSONHandler[sampleObject]())
+			require.NoError(t, res.Error)
+			assert.Equal(t, createSampleObject(), res.Res)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&u.calls))
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	entry := func(body string) *CachedResponse {
+		return &CachedResponse{StatusCode: http.StatusOK, Body: []byte(body)}
+	}
+
+	t.Run("when the cache is full, the least recently used response should be evicted", func(t *testing.T) {
+		cache := NewLRUCache(30)
+		cache.Set("a", entry("0123456789"))
+		cache.Set("b", entry("0123456789"))
+		_, ok := cache.Get("a")
+		require.True(t, ok)
+
+		cache.Set("c", entry("0123456789"))
+		_, ok = cache.Get("b")
+		assert.False(t, ok)
+		_, ok = cache.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 2, cache.Len())
+	})
+
+	t.Run("when a response is larger than the cache, it should not be stored", func(t *testing.T) {
+		cache := NewLRUCache(5)
+		cache.Set("a", entry("0123456789"))
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("when a key is set again or deleted, its old response should be replaced or removed", func(t *testing.T) {
+		cache := NewLRUCache(100)
+		cache.Set("a", entry("old"))
+		cache.Set("a", entry("new"))
+		res, ok := cache.Get("a")
+		require.True(t, ok)
+		assert.Equal(t, []byte("new"), res.Body)
+
+		cache.Delete("a")
+		_, ok = cache.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, cache.Len())
+	})
+}
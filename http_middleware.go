@@ -0,0 +1,285 @@
+package pipelines
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HttpClientFunc lets an ordinary function be used as a HttpClient.
+type HttpClientFunc func(req *http.Request) (*http.Response, error)
+
+func (f HttpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a HttpClient with behaviour of its own, such as adding headers to every request.
+type Middleware func(HttpClient) HttpClient
+
+// Chain wraps httpClient with each of middlewares, the first of them being the outermost, so the first to see each
+// request and the last to see each response.
+func Chain(httpClient HttpClient, middlewares ...Middleware) HttpClient {
+	if httpClient == nil {
+		panic("Chain: httpClient arg has nil value")
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] == nil {
+			panic("Chain: middleware arg has nil value")
+		}
+		httpClient = middlewares[i](httpClient)
+	}
+	return httpClient
+}
+
+// StaticHeaders adds h to every request that doesn't already carry those headers, e.g. to tag requests with a User-Agent.
+func StaticHeaders(h http.Header) Middleware {
+	return func(next HttpClient) HttpClient {
+		return HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			for key, values := range h {
+				if _, ok := req.Header[http.CanonicalHeaderKey(key)]; ok {
+					continue
+				}
+				for _, v := range values {
+					req.Header.Add(key, v)
+				}
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// RequestID gives every request that doesn't already have one a random id in the named header, or X-Request-Id
+// when header is empty.
+func RequestID(header string) Middleware {
+	if header == "" {
+		header = "X-Request-Id"
+	}
+
+	return func(next HttpClient) HttpClient {
+		return HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) != "" {
+				return next.Do(req)
+			}
+
+			id := make([]byte, 16)
+			_, _ = rand.Read(id)
+			req = req.Clone(req.Context())
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			req.Header.Set(header, hex.EncodeToString(id))
+			return next.Do(req)
+		})
+	}
+}
+
+// Timeout gives every request d to complete, including the reading of its response body.
+func Timeout(d time.Duration) Middleware {
+	return func(next HttpClient) HttpClient {
+		return HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			res, err := next.Do(req.WithContext(ctx))
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+			return res, nil
+		})
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// TokenSource provides the tokens used by BearerToken. A zero expiry means the token is good until the server rejects it.
+type TokenSource interface {
+	Token(ctx context.Context) (token string, expiry time.Time, err error)
+}
+
+type TokenSourceFunc func(ctx context.Context) (string, time.Time, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, time.Time, error) {
+	return f(ctx)
+}
+
+// tokenExpiryLeeway is how long before its expiry a token is refreshed, so that it doesn't expire in flight.
+const tokenExpiryLeeway = time.Second * 30
+
+type BearerTokenOptions struct {
+	// Clock tells when a token is about to expire.
+	Clock Clock
+}
+
+type BearerTokenOption func(*BearerTokenOptions)
+
+// BearerToken authorises every request with a bearer token from source. The token is reused until it is about to
+// expire, or until a request is rejected with a 401, in which case the token is refreshed and the request sent once more.
+func BearerToken(source TokenSource, options ...BearerTokenOption) Middleware {
+	if source == nil {
+		panic("BearerToken: source arg has nil value")
+	}
+
+	ops := BearerTokenOptions{
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	return func(next HttpClient) HttpClient {
+		b := &bearerToken{next: next, source: source, clock: ops.Clock}
+		return HttpClientFunc(b.Do)
+	}
+}
+
+type bearerToken struct {
+	next   HttpClient
+	source TokenSource
+	clock  Clock
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (b *bearerToken) Do(req *http.Request) (*http.Response, error) {
+	token, err := b.get(req.Context(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.next.Do(withBearer(req, req.Clone(req.Context()), token))
+	if err != nil || res.StatusCode != http.StatusUnauthorized || !replayable(req) {
+		return res, err
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	token, err = b.get(req.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := cloneRequest(req.Context(), req)
+	if err != nil {
+		return nil, err
+	}
+	return b.next.Do(withBearer(req, retry, token))
+}
+
+// get returns the cached token, fetching a new one if it is about to expire or if it is the rejected one.
+func (b *bearerToken) get(ctx context.Context, rejected string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	valid := b.token != "" && (b.expiry.IsZero() || b.expiry.Sub(b.clock.Now()) > tokenExpiryLeeway)
+	if valid && b.token != rejected {
+		return b.token, nil
+	}
+
+	token, expiry, err := b.source.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	b.token, b.expiry = token, expiry
+	return token, nil
+}
+
+func withBearer(orig, req *http.Request, token string) *http.Request {
+	req.Header = orig.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// replayable reports whether req can be sent a second time.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// DefaultRedactedHeaders are the headers whose values DumpLogging never logs.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// DumpLogging logs the method, URL and headers of every request and response at debug level. The values of
+// DefaultRedactedHeaders, and of any further headers named, are replaced with "REDACTED", as are the values of the
+// query parameters, which often carry keys and signatures, and any user info in the URL. Bodies are not logged.
+func DumpLogging(logger Logger, redactHeaders ...string) Middleware {
+	if logger == nil {
+		panic("DumpLogging: logger arg has nil value")
+	}
+
+	redact := append(append([]string(nil), DefaultRedactedHeaders...), redactHeaders...)
+
+	return func(next HttpClient) HttpClient {
+		return HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			dumpReq := req.Clone(req.Context())
+			dumpReq.Header = redacted(req.Header, redact)
+			dumpReq.Body = nil
+			dumpReq.URL.User = nil
+			dumpReq.URL.RawQuery = redactedQuery(req.URL.Query())
+			if dump, err := httputil.DumpRequestOut(dumpReq, false); err == nil {
+				logger.Debug("http request", "dump", strings.TrimSpace(string(dump)))
+			}
+
+			start := time.Now()
+			res, err := next.Do(req)
+			if err != nil {
+				logger.Debug("http request failed", "error", err, "duration", time.Since(start))
+				return nil, err
+			}
+
+			dumpRes := *res
+			dumpRes.Header = redacted(res.Header, redact)
+			dumpRes.Body = nil
+			if dump, err := httputil.DumpResponse(&dumpRes, false); err == nil {
+				logger.Debug("http response", "dump", strings.TrimSpace(string(dump)), "duration", time.Since(start))
+			}
+			return res, nil
+		})
+	}
+}
+
+func redactedQuery(q url.Values) string {
+	for _, values := range q {
+		for i := range values {
+			values[i] = "REDACTED"
+		}
+	}
+	return q.Encode()
+}
+
+func redacted(h http.Header, redact []string) http.Header {
+	out := h.Clone()
+	if out == nil {
+		return http.Header{}
+	}
+	for _, key := range redact {
+		if _, ok := out[http.CanonicalHeaderKey(key)]; ok {
+			out.Set(key, "REDACTED")
+		}
+	}
+	return out
+}
@@ -2,7 +2,9 @@ package pipelines
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 type HttpClient interface {
@@ -12,46 +14,86 @@ type HttpResponseHandler[T any] func(*http.Response, error) (T, error)
 type HttpReqAsyncResponse[T any] struct {
 	Res   T
 	Error error
+	// Attempt identifies which attempt produced the response when it comes from HttpReqHedged, the original
+	// request being attempt 0.
+	Attempt int
 }
 
 func HttpReqAsync[T any](ctx context.Context, httpClient HttpClient, req *http.Request, resHandler HttpResponseHandler[T]) <-chan HttpReqAsyncResponse[T] {
 	res := make(chan HttpReqAsyncResponse[T])
-	errStream := make(chan error)
-	httpReqStream := make(chan T)
+	// buffered so that the request can always complete, even once we've stopped waiting for it
+	reqStream := make(chan HttpReqAsyncResponse[T], 1)
+	observer := ObserverFromContext(ctx)
+	logger := LoggerFromContext(ctx).With("stage", "HttpReqAsync", "method", req.Method, "url", req.URL.Redacted())
 
 	go func() {
 		defer close(res)
-		for {
+		var r HttpReqAsyncResponse[T]
+		select {
+		case r = <-reqStream:
+		case <-ctx.Done():
+			// a response that arrived as ctx was cancelled is still worth having
 			select {
-			case <-ctx.Done():
-				res <- HttpReqAsyncResponse[T]{Error: ctx.Err()}
-			case err, ok := <-errStream:
-				if !ok {
-					// errStream channel closed - handle this in way that suits your app
-					return
-				}
-				res <- HttpReqAsyncResponse[T]{Error: err}
-			case httpGetItem, ok := <-httpReqStream:
-				if !ok {
-					// httpReqStream channel closed - handle this in way that suits your app
-					return
-				}
-				res <- HttpReqAsyncResponse[T]{Res: httpGetItem}
+			case r = <-reqStream:
+			default:
+				observer.Cancelled("HttpReqAsync")
+				logger.Debug("request cancelled", "error", ctx.Err())
+				r = HttpReqAsyncResponse[T]{Error: ctx.Err()}
 			}
 		}
+
+		sendStart := time.Now()
+		res <- r
+		observer.BlockedSend("HttpReqAsync", time.Since(sendStart))
+		observer.ItemOut("HttpReqAsync")
 	}()
 
 	go func() {
-		defer close(errStream)
-		defer close(httpReqStream)
-
-		getItem, err := resHandler(httpClient.Do(req))
+		observer.ItemIn("HttpReqAsync")
+		logger.Debug("sending request")
+		start := time.Now()
+		getItem, err := resHandler(doTraced(ctx, httpClient, req))
+		observer.WorkerLatency("HttpReqAsync", time.Since(start))
 		if err != nil {
-			errStream <- err
+			logger.Error("request failed", "error", err, "duration", time.Since(start))
+			reqStream <- HttpReqAsyncResponse[T]{Error: err}
 			return
 		}
-		httpReqStream <- getItem
+		logger.Debug("request completed", "duration", time.Since(start))
+		reqStream <- HttpReqAsyncResponse[T]{Res: getItem}
 	}()
 
 	return res
 }
+
+// doTraced sends req within a span when ctx, or the request's own context, is part of a trace, passing the span on to
+// the server in a W3C traceparent header.
+func doTraced(ctx context.Context, httpClient HttpClient, req *http.Request) (*http.Response, error) {
+	if _, ok := SpanContextFromContext(ctx); !ok {
+		ctx = req.Context()
+		if _, ok := SpanContextFromContext(ctx); !ok {
+			return httpClient.Do(req)
+		}
+	}
+
+	ctx, finish := StartSpan(ctx, "HttpReqAsync")
+	sc, _ := SpanContextFromContext(ctx)
+	req = req.Clone(req.Context())
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("traceparent", sc.Traceparent())
+
+	res, err := httpClient.Do(req)
+	switch {
+	case err != nil:
+		finish(err)
+	case res == nil:
+		finish(ErrNilResponse)
+	case res.StatusCode >= http.StatusInternalServerError:
+		finish(fmt.Errorf("HttpReqAsync: %s", res.Status))
+	default:
+		finish(nil)
+	}
+	return res, err
+}
@@ -158,6 +158,25 @@ func Test_httpReqAsync(t *testing.T) {
 		assert.Error(t, res.Error)
 		assert.Equal(t, res.Error.Error(), fmt.Sprintf("failed to unmarshal object: %v. Response: %s, Body: %s", errors.New("invalid character 'e' in literal true (expecting 'r')"), http.StatusText(http.StatusOK), "test"))
 	})
+
+	t.Run("Should return the context error and close the channel if the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		release := make(chan struct{})
+		defer close(release)
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			<-release
+			return nil, errors.New("too late")
+		})
+
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1/?expand=metadata", nil)
+		require.NoError(t, err)
+
+		resStream := HttpReqAsync(ctx, client, req, resHandler)
+		cancel()
+		res := <-resStream
+		assert.ErrorIs(t, res.Error, context.Canceled)
+		expectClosedChannel(true, resStream, t)
+	})
 }
 
 func Test_httpReqAsyncWithHeartbeats(t *testing.T) {
@@ -0,0 +1,143 @@
+package pipelines
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type HedgeOptions struct {
+	// Delay is how long to wait for an answer before sending the next attempt. An attempt that fails is followed by
+	// the next one straight away.
+	Delay time.Duration
+	// MaxAttempts is the most requests that will be sent, including the original. It defaults to the number of
+	// Replicas, or 2 if there are none.
+	MaxAttempts int
+	// Replicas are the hosts that attempts are sent to in turn, attempt n going to Replicas[n%len(Replicas)]. When
+	// empty, every attempt is sent to the request's own URL.
+	Replicas []string
+
+	Clock Clock
+}
+
+type HedgeOption func(*HedgeOptions)
+
+// HttpReqHedged sends req and, if no good answer has arrived within the hedge Delay, sends it again, up to MaxAttempts.
+// The first successful response is returned, noting the attempt that produced it, and the outstanding attempts are
+// cancelled. If every attempt fails, the last failure is returned.
+//
+// Requests with a body can only be resent when req.GetBody is set, as it is by http.NewRequest.
+func HttpReqHedged[T any](ctx context.Context, httpClient HttpClient, req *http.Request, resHandler HttpResponseHandler[T], options ...HedgeOption) <-chan HttpReqAsyncResponse[T] {
+	if httpClient == nil {
+		panic("HttpReqHedged: httpClient arg has nil value")
+	}
+
+	if req == nil {
+		panic("HttpReqHedged: req arg has nil value")
+	}
+
+	if resHandler == nil {
+		panic("HttpReqHedged: resHandler arg has nil value")
+	}
+
+	ops := HedgeOptions{
+		Delay: time.Millisecond * 100,
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	if ops.MaxAttempts < 1 {
+		ops.MaxAttempts = 2
+		if len(ops.Replicas) > 0 {
+			ops.MaxAttempts = len(ops.Replicas)
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		ops.MaxAttempts = 1
+	}
+
+	res := make(chan HttpReqAsyncResponse[T])
+
+	go func() {
+		defer close(res)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make(chan HttpReqAsyncResponse[T], ops.MaxAttempts)
+		var sent, pending int
+		launch := func() {
+			attempt := sent
+			sent++
+			pending++
+
+			r, err := cloneRequest(ctx, req)
+			if err != nil {
+				results <- HttpReqAsyncResponse[T]{Error: err, Attempt: attempt}
+				return
+			}
+			if len(ops.Replicas) > 0 {
+				r.URL.Host = ops.Replicas[attempt%len(ops.Replicas)]
+				r.Host = ""
+			}
+
+			go func() {
+				out := <-HttpReqAsync(ctx, httpClient, r, resHandler)
+				out.Attempt = attempt
+				results <- out
+			}()
+		}
+
+		timer := ops.Clock.NewTimer(ops.Delay)
+		defer timer.Stop()
+		launch()
+
+		var last HttpReqAsyncResponse[T]
+		for pending > 0 {
+			select {
+			case <-ctx.Done():
+				last = HttpReqAsyncResponse[T]{Error: ctx.Err()}
+				pending = 0
+			case <-timer.C():
+				if sent < ops.MaxAttempts {
+					launch()
+					timer.Reset(ops.Delay)
+				}
+			case out := <-results:
+				pending--
+				if out.Error == nil {
+					// the losers shouldn't be kept open for as long as the consumer takes to read the winner
+					cancel()
+					res <- out
+					return
+				}
+				last = out
+				if sent < ops.MaxAttempts {
+					launch()
+					// the delay may have run out as the failure arrived, and its tick mustn't launch another straight away
+					resetTimer(timer, ops.Delay)
+				}
+			}
+		}
+
+		cancel()
+		res <- last
+	}()
+
+	return res
+}
+
+// cloneRequest copies req for another attempt, bound to ctx, with a fresh copy of its body.
+func cloneRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	r := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
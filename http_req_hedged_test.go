@@ -0,0 +1,146 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpReqHedged(t *testing.T) {
+	bodyHandler := func(res *http.Response, err error) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return string(body), err
+	}
+
+	okResponse := func(body string) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) { return okResponse(""), nil })
+
+		assert.Panics(t, func() { HttpReqHedged(context.Background(), nil, req, bodyHandler) })
+		assert.Panics(t, func() { HttpReqHedged(context.Background(), client, nil, bodyHandler) })
+		assert.Panics(t, func() { HttpReqHedged[string](context.Background(), client, req, nil) })
+	})
+
+	t.Run("when the first attempt answers within the delay, we should send no other", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := 0
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return okResponse("first"), nil
+		})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		res := <-HttpReqHedged(context.Background(), client, req, bodyHandler, func(ho *HedgeOptions) { ho.Clock = newFakeClock() })
+		require.NoError(t, res.Error)
+		assert.Equal(t, "first", res.Res)
+		assert.Equal(t, 0, res.Attempt)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("when the first replica is slow, we should take the backup's answer and cancel the first", func(t *testing.T) {
+		clock := newFakeClock()
+		cancelled := make(chan struct{})
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Host == "replica-a" {
+				<-r.Context().Done()
+				close(cancelled)
+				return nil, r.Context().Err()
+			}
+			return okResponse("from " + r.URL.Host), nil
+		})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		resStream := HttpReqHedged(context.Background(), client, req, bodyHandler, func(ho *HedgeOptions) {
+			ho.Delay = time.Millisecond * 50
+			ho.Replicas = []string{"replica-a", "replica-b"}
+			ho.Clock = clock
+		})
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond * 50)
+
+		// the loser should be cancelled before the winner is read, not once it has been
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Error("expected the losing attempt to be cancelled")
+		}
+
+		res := <-resStream
+		require.NoError(t, res.Error)
+		assert.Equal(t, "from replica-b", res.Res)
+		assert.Equal(t, 1, res.Attempt)
+	})
+
+	t.Run("when an attempt fails, we should send the next without waiting for the delay", func(t *testing.T) {
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Host == "replica-a" {
+				return nil, errors.New("connection refused")
+			}
+			body, _ := io.ReadAll(r.Body)
+			return okResponse(string(body)), nil
+		})
+		req, err := http.NewRequest(http.MethodPost, "https://my-api/objects", strings.NewReader("payload"))
+		require.NoError(t, err)
+
+		res := <-HttpReqHedged(context.Background(), client, req, bodyHandler, func(ho *HedgeOptions) {
+			ho.Replicas = []string{"replica-a", "replica-b"}
+			ho.Clock = newFakeClock()
+		})
+		require.NoError(t, res.Error)
+		assert.Equal(t, "payload", res.Res)
+		assert.Equal(t, 1, res.Attempt)
+	})
+
+	t.Run("when every attempt fails, we should receive the last failure", func(t *testing.T) {
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("unavailable: " + r.URL.Host)
+		})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		res := <-HttpReqHedged(context.Background(), client, req, bodyHandler, func(ho *HedgeOptions) {
+			ho.Replicas = []string{"replica-a", "replica-b", "replica-c"}
+			ho.Clock = newFakeClock()
+		})
+		assert.EqualError(t, res.Error, "unavailable: replica-c")
+		assert.Equal(t, 2, res.Attempt)
+	})
+
+	t.Run("when ctx is cancelled, we should receive its error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		resStream := HttpReqHedged(ctx, client, req, bodyHandler, func(ho *HedgeOptions) { ho.Clock = newFakeClock() })
+		cancel()
+		res := <-resStream
+		assert.ErrorIs(t, res.Error, context.Canceled)
+	})
+}
@@ -0,0 +1,183 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodySize is the most that the response handlers in this package will read from a response body, unless
+// they are wrapped with WithMaxBodySize.
+const DefaultMaxBodySize int64 = 10 << 20
+
+// maxErrorBodySize is the most of a response body that is kept in a HTTPStatusError.
+const maxErrorBodySize = 1024
+
+var ErrBodyTooLarge = errors.New("response body exceeds max size")
+
+// ErrNilResponse is returned by the response handlers in this package when they are given neither a response nor an
+// error.
+var ErrNilResponse = errors.New("nil response")
+
+// HTTPStatusError is returned by the response handlers in this package when a response has an unexpected status code.
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds no more than the first 1KiB of the response body.
+	Body      []byte
+	Truncated bool
+}
+
+func (e *HTTPStatusError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Truncated {
+		return fmt.Sprintf("unexpected response status: %s, body: %s...", status, e.Body)
+	}
+	return fmt.Sprintf("unexpected response status: %s, body: %s", status, e.Body)
+}
+
+// JSONHandler returns a HttpResponseHandler that decodes a JSON body into T, treating any 2xx status as success. An
+// empty body decodes to the zero value of T.
+func JSONHandler[T any]() HttpResponseHandler[T] {
+	return JSONHandlerWithStatus[T]()
+}
+
+// JSONHandlerWithStatus returns a HttpResponseHandler that decodes a JSON body into T, treating only the given status
+// codes as success. With no codes given, any 2xx status is a success.
+func JSONHandlerWithStatus[T any](okCodes ...int) HttpResponseHandler[T] {
+	return func(res *http.Response, err error) (T, error) {
+		var t T
+		body, err := readBody(res, err, okCodes)
+		if err != nil {
+			return t, err
+		}
+
+		if len(body) == 0 {
+			return t, nil
+		}
+		if err := json.Unmarshal(body, &t); err != nil {
+			return t, fmt.Errorf("failed to unmarshal response body: %w", err)
+		}
+		return t, nil
+	}
+}
+
+// BytesHandler is a HttpResponseHandler that returns the body of any 2xx response.
+func BytesHandler(res *http.Response, err error) ([]byte, error) {
+	return readBody(res, err, nil)
+}
+
+// DiscardHandler is a HttpResponseHandler that checks for a 2xx status and throws the body away, whatever its size
+// unless it is wrapped with WithMaxBodySize.
+func DiscardHandler(res *http.Response, err error) (struct{}, error) {
+	if _, err := checkResponse(res, err, nil); err != nil {
+		return struct{}{}, err
+	}
+	defer res.Body.Close()
+
+	// as nothing is kept, there's no need for the DefaultMaxBodySize
+	_, err = io.Copy(io.Discard, res.Body)
+	return struct{}{}, err
+}
+
+// WithMaxBodySize wraps resHandler so that it fails with ErrBodyTooLarge if it reads more than n bytes of a response
+// body. It replaces the DefaultMaxBodySize used by the response handlers in this package.
+func WithMaxBodySize[T any](n int64, resHandler HttpResponseHandler[T]) HttpResponseHandler[T] {
+	if resHandler == nil {
+		panic("WithMaxBodySize: resHandler arg has nil value")
+	}
+
+	return func(res *http.Response, err error) (T, error) {
+		if err == nil && res != nil && res.Body != nil {
+			res.Body = &limitedBody{ReadCloser: res.Body, remaining: n}
+		}
+		return resHandler(res, err)
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// read one byte past the limit so that we can tell an oversized body from one that is exactly n bytes long
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// readBody reads and closes the body of res, once checkResponse is happy with it.
+func readBody(res *http.Response, err error, okCodes []int) ([]byte, error) {
+	body, err := checkResponse(res, err, okCodes)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(body)
+}
+
+// checkResponse returns the body of res, limited to DefaultMaxBodySize unless a limit has already been set, or a
+// HTTPStatusError if its status is not one of okCodes, or not 2xx when there are none. The body is closed on error.
+func checkResponse(res *http.Response, err error, okCodes []int) (io.Reader, error) {
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, ErrNilResponse
+	}
+	if res.Body == nil {
+		res.Body = http.NoBody
+	}
+
+	body := res.Body
+	if _, ok := body.(*limitedBody); !ok {
+		body = &limitedBody{ReadCloser: body, remaining: DefaultMaxBodySize}
+	}
+
+	if !statusOK(res.StatusCode, okCodes) {
+		defer res.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySize+1))
+		truncated := len(b) > maxErrorBodySize
+		if truncated {
+			b = b[:maxErrorBodySize]
+		}
+		return nil, &HTTPStatusError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Header:     res.Header,
+			Body:       b,
+			Truncated:  truncated,
+		}
+	}
+
+	return body, nil
+}
+
+func statusOK(code int, okCodes []int) bool {
+	if len(okCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, ok := range okCodes {
+		if code == ok {
+			return true
+		}
+	}
+	return false
+}
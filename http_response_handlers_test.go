@@ -0,0 +1,193 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONHandler(t *testing.T) {
+	newResponse := func(code int, body string) *http.Response {
+		return &http.Response{
+			Status:     http.StatusText(code),
+			StatusCode: code,
+			Header:     http.Header{"X-Request-Id": []string{"abc"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("when we receive a 2xx response, we should decode the body", func(t *testing.T) {
+		res, err := JSONHandler[sampleObject]()(newResponse(http.StatusCreated, `{"_id":1,"name":"John","age":30}`), nil)
+		require.NoError(t, err)
+		assert.Equal(t, createSampleObject(), res)
+	})
+
+	t.Run("when the body is empty, we should receive the zero value", func(t *testing.T) {
+		res, err := JSONHandler[sampleObject]()(newResponse(http.StatusNoContent, ""), nil)
+		require.NoError(t, err)
+		assert.Equal(t, sampleObject{}, res)
+	})
+
+	t.Run("when the client errors, we should receive that error", func(t *testing.T) {
+		errClient := errors.New("connection reset")
+		_, err := JSONHandler[sampleObject]()(nil, errClient)
+		assert.ErrorIs(t, err, errClient)
+	})
+
+	t.Run("when the body isn't valid JSON, we should receive an error", func(t *testing.T) {
+		_, err := JSONHandler[sampleObject]()(newResponse(http.StatusOK, "test"), nil)
+		assert.ErrorContains(t, err, "failed to unmarshal response body")
+	})
+
+	t.Run("when we receive a non 2xx response, we should receive a HTTPStatusError", func(t *testing.T) {
+		_, err := JSONHandler[sampleObject]()(newResponse(http.StatusNotFound, "no such object"), nil)
+
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		assert.Equal(t, "abc", statusErr.Header.Get("X-Request-Id"))
+		assert.Equal(t, []byte("no such object"), statusErr.Body)
+		assert.False(t, statusErr.Truncated)
+		assert.Equal(t, "unexpected response status: Not Found, body: no such object", err.Error())
+	})
+
+	t.Run("when an error response has a large body, the HTTPStatusError should hold a truncated copy", func(t *testing.T) {
+		_, err := JSONHandler[sampleObject]()(newResponse(http.StatusBadGateway, strings.Repeat("x", 5000)), nil)
+
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Len(t, statusErr.Body, maxErrorBodySize)
+		assert.True(t, statusErr.Truncated)
+	})
+
+	t.Run("when we specify okCodes, only those codes should be treated as success", func(t *testing.T) {
+		handler := JSONHandlerWithStatus[sampleObject](http.StatusOK, http.StatusAccepted)
+
+		_, err := handler(newResponse(http.StatusAccepted, `{"_id":1}`), nil)
+		assert.NoError(t, err)
+
+		_, err = handler(newResponse(http.StatusCreated, `{"_id":1}`), nil)
+		var statusErr *HTTPStatusError
+		assert.ErrorAs(t, err, &statusErr)
+	})
+
+	t.Run("when used with HttpReqAsync, we should receive the decoded object", func(t *testing.T) {
+		client := &http.Client{
+			Transport: newStubRoundTripper(newResponse(http.StatusOK, `{"_id":1,"name":"John","age":30}`), nil),
+		}
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1/?expand=metadata", nil)
+		require.NoError(t, err)
+
+		res := <-HttpReqAsync(context.TODO(), client, req, JSONHandler[sampleObject]())
+		assert.NoError(t, res.Error)
+		assert.Equal(t, createSampleObject(), res.Res)
+	})
+}
+
+func TestBytesHandler(t *testing.T) {
+	t.Run("when we receive a 2xx response, we should receive the body", func(t *testing.T) {
+		body, err := BytesHandler(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("raw"))}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("raw"), body)
+	})
+
+	t.Run("when we receive a non 2xx response, we should receive a HTTPStatusError", func(t *testing.T) {
+		_, err := BytesHandler(&http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("oops"))}, nil)
+		var statusErr *HTTPStatusError
+		assert.ErrorAs(t, err, &statusErr)
+	})
+}
+
+func TestDiscardHandler(t *testing.T) {
+	t.Run("when we receive a 2xx response, the body should be read and closed", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader("ignored")}
+		_, err := DiscardHandler(&http.Response{StatusCode: http.StatusOK, Body: body}, nil)
+		require.NoError(t, err)
+		assert.True(t, body.closed)
+		assert.Equal(t, 0, body.Len())
+	})
+
+	t.Run("when we receive a non 2xx response, the body should still be closed", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader("oops")}
+		_, err := DiscardHandler(&http.Response{StatusCode: http.StatusForbidden, Body: body}, nil)
+		var statusErr *HTTPStatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.True(t, body.closed)
+	})
+
+	t.Run("when the body is larger than DefaultMaxBodySize, it should still be discarded", func(t *testing.T) {
+		body := io.NopCloser(io.LimitReader(zeroReader{}, DefaultMaxBodySize+1))
+		_, err := DiscardHandler(&http.Response{StatusCode: http.StatusOK, Body: body}, nil)
+		assert.NoError(t, err)
+	})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestResponseHandlersWithNilResponse(t *testing.T) {
+	t.Run("when there is neither a response nor an error, every handler should receive ErrNilResponse", func(t *testing.T) {
+		_, err := JSONHandler[sampleObject]()(nil, nil)
+		assert.ErrorIs(t, err, ErrNilResponse)
+		_, err = BytesHandler(nil, nil)
+		assert.ErrorIs(t, err, ErrNilResponse)
+		_, err = DiscardHandler(nil, nil)
+		assert.ErrorIs(t, err, ErrNilResponse)
+	})
+
+	t.Run("when a response has no body, it should be treated as empty", func(t *testing.T) {
+		res, err := JSONHandler[sampleObject]()(&http.Response{StatusCode: http.StatusOK}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, sampleObject{}, res)
+		_, err = DiscardHandler(&http.Response{StatusCode: http.StatusOK}, nil)
+		assert.NoError(t, err)
+	})
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	t.Run("when resHandler has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { WithMaxBodySize[[]byte](10, nil) })
+	})
+
+	t.Run("when the body is within the limit, we should receive all of it", func(t *testing.T) {
+		body, err := WithMaxBodySize(5, BytesHandler)(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("12345"))}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("12345"), body)
+	})
+
+	t.Run("when the body exceeds the limit, we should receive ErrBodyTooLarge", func(t *testing.T) {
+		_, err := WithMaxBodySize(5, BytesHandler)(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("123456"))}, nil)
+		assert.ErrorIs(t, err, ErrBodyTooLarge)
+	})
+
+	t.Run("when the limit is above the default, it should replace it", func(t *testing.T) {
+		large := bytes.Repeat([]byte("x"), int(DefaultMaxBodySize)+1)
+		_, err := BytesHandler(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(large))}, nil)
+		assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+		body, err := WithMaxBodySize(DefaultMaxBodySize*2, BytesHandler)(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(large))}, nil)
+		require.NoError(t, err)
+		assert.Len(t, body, len(large))
+	})
+}
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
@@ -0,0 +1,184 @@
+package pipelines
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+type StreamFormat int
+
+const (
+	// StreamFormatAuto picks StreamFormatSSE for a text/event-stream response and StreamFormatNDJSON for anything else.
+	StreamFormatAuto StreamFormat = iota
+	// StreamFormatNDJSON treats each non-empty line of the body as a JSON item.
+	StreamFormatNDJSON
+	// StreamFormatSSE treats the data of each server-sent event as a JSON item, or as the item itself when T is a string.
+	StreamFormatSSE
+)
+
+type HttpStreamOptions struct {
+	Format StreamFormat
+	// MaxLineSize is the longest line that can be read from the body.
+	MaxLineSize int
+	// OkCodes are the status codes that the response may have. By default any 2xx status is accepted.
+	OkCodes []int
+}
+
+type HttpStreamOption func(*HttpStreamOptions)
+
+// HttpStream sends req and emits the items decoded from the response body as they arrive, rather than waiting for the
+// whole body. Any error, including an unexpected status code as a *HTTPStatusError, ends the stream and is sent on the
+// error stream. The body is closed when the stream ends or ctx is cancelled.
+func HttpStream[T any](ctx context.Context, httpClient HttpClient, req *http.Request, options ...HttpStreamOption) (<-chan T, <-chan error) {
+	if httpClient == nil {
+		panic("HttpStream: httpClient arg has nil value")
+	}
+
+	if req == nil {
+		panic("HttpStream: req arg has nil value")
+	}
+
+	ops := HttpStreamOptions{
+		Format:      StreamFormatAuto,
+		MaxLineSize: 1 << 20,
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	outStream := make(chan T)
+	errStream := make(chan error, 1)
+
+	go func() {
+		defer close(outStream)
+		defer close(errStream)
+
+		res, err := httpClient.Do(req.Clone(ctx))
+		if _, err := checkResponse(res, err, ops.OkCodes); err != nil {
+			errStream <- err
+			return
+		}
+
+		var closeOnce sync.Once
+		closeBody := func() { closeOnce.Do(func() { res.Body.Close() }) }
+		defer closeBody()
+
+		// not every HttpClient honours the request context, so make sure a blocked read is interrupted
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				closeBody()
+			case <-stop:
+			}
+		}()
+
+		format := ops.Format
+		if format == StreamFormatAuto {
+			format = StreamFormatNDJSON
+			if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+				format = StreamFormatSSE
+			}
+		}
+
+		var decodeErr error
+		emit := func(data []byte) bool {
+			item, err := decodeStreamItem[T](data)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case outStream <- item:
+				return true
+			}
+		}
+
+		// the body is deliberately not limited to DefaultMaxBodySize, as a stream may go on for as long as it likes
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(make([]byte, 0, 4096), ops.MaxLineSize)
+
+		if format == StreamFormatSSE {
+			err = scanEvents(scanner, emit)
+		} else {
+			err = scanLines(scanner, emit)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			errStream <- ctx.Err()
+		case decodeErr != nil:
+			errStream <- decodeErr
+		case err != nil:
+			errStream <- err
+		}
+	}()
+
+	return outStream, errStream
+}
+
+func scanLines(scanner *bufio.Scanner, emit func([]byte) bool) error {
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !emit(line) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// scanEvents follows the text/event-stream format, emitting the data of each event once the blank line ending it is
+// read. Event types, ids and retry hints are ignored.
+func scanEvents(scanner *bufio.Scanner, emit func([]byte) bool) error {
+	var data []byte
+	var hasData bool
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if hasData && !emit(data) {
+				return nil
+			}
+			data, hasData = data[:0], false
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		if string(field) != "data" {
+			continue
+		}
+		value = bytes.TrimPrefix(value, []byte(" "))
+		if hasData {
+			data = append(data, '\n')
+		}
+		data = append(data, value...)
+		hasData = true
+	}
+
+	// an event left incomplete by the end of the body is discarded, as the format requires
+	return scanner.Err()
+}
+
+func decodeStreamItem[T any](data []byte) (T, error) {
+	var t T
+	if s, ok := interface{}(&t).(*string); ok {
+		*s = string(data)
+		return t, nil
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("HttpStream: failed to unmarshal item: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,120 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpStream(t *testing.T) {
+	newClient := func(contentType string, body io.ReadCloser) HttpClient {
+		return HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       body,
+			}, nil
+		})
+	}
+
+	newRequest := func(t *testing.T) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/stream", nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	collect := func(outStream <-chan sampleObject, errStream <-chan error) ([]sampleObject, error) {
+		got := make([]sampleObject, 0)
+		for o := range outStream {
+			got = append(got, o)
+		}
+		return got, <-errStream
+	}
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { HttpStream[sampleObject](context.Background(), nil, newRequest(t)) })
+		assert.Panics(t, func() { HttpStream[sampleObject](context.Background(), newClient("", http.NoBody), nil) })
+	})
+
+	t.Run("when we receive newline delimited JSON, we should receive each line as an item", func(t *testing.T) {
+		body := io.NopCloser(strings.NewReader("{\"_id\":1,\"name\":\"John\",\"age\":30}\n\n{\"_id\":2}\n"))
+		got, err := collect(HttpStream[sampleObject](context.Background(), newClient("application/x-ndjson", body), newRequest(t)))
+		require.NoError(t, err)
+		assert.Equal(t, []sampleObject{createSampleObject(), {Id: 2}}, got)
+	})
+
+	t.Run("when we receive server-sent events, we should receive the data of each event as an item", func(t *testing.T) {
+		body := io.NopCloser(strings.NewReader(": comment\r\nevent: update\r\ndata: {\"_id\":1,\r\ndata: \"name\":\"John\",\"age\":30}\r\n\r\nid: 7\ndata:{\"_id\":2}\n\ndata: {\"_id\":3}"))
+		got, err := collect(HttpStream[sampleObject](context.Background(), newClient("text/event-stream; charset=utf-8", body), newRequest(t)))
+		require.NoError(t, err)
+		assert.Equal(t, []sampleObject{createSampleObject(), {Id: 2}}, got)
+	})
+
+	t.Run("when T is a string, we should receive the raw event data", func(t *testing.T) {
+		body := io.NopCloser(strings.NewReader("data: fader 1 moved\n\n"))
+		outStream, errStream := HttpStream[string](context.Background(), newClient("", body), newRequest(t), func(o *HttpStreamOptions) {
+			o.Format = StreamFormatSSE
+		})
+		expectOrderedResultsList([]string{"fader 1 moved"}, outStream, t)
+		assert.NoError(t, <-errStream)
+	})
+
+	t.Run("when a line can't be decoded, the stream should end with an error", func(t *testing.T) {
+		body := io.NopCloser(strings.NewReader("{\"_id\":1}\nnot json\n{\"_id\":3}\n"))
+		got, err := collect(HttpStream[sampleObject](context.Background(), newClient("", body), newRequest(t)))
+		assert.ErrorContains(t, err, "failed to unmarshal item")
+		assert.Equal(t, []sampleObject{{Id: 1}}, got)
+	})
+
+	t.Run("when we receive an unexpected status, we should receive a HTTPStatusError", func(t *testing.T) {
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader("denied"))}, nil
+		})
+		got, err := collect(HttpStream[sampleObject](context.Background(), client, newRequest(t)))
+		var statusErr *HTTPStatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.Empty(t, got)
+	})
+
+	t.Run("when the client errors, we should receive that error", func(t *testing.T) {
+		errClient := errors.New("connection refused")
+		client := HttpClientFunc(func(r *http.Request) (*http.Response, error) { return nil, errClient })
+		_, err := collect(HttpStream[sampleObject](context.Background(), client, newRequest(t)))
+		assert.ErrorIs(t, err, errClient)
+	})
+
+	t.Run("when ctx is cancelled mid stream, the body should be closed and the stream should end", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		ctx, cancel := context.WithCancel(context.Background())
+		outStream, errStream := HttpStream[sampleObject](ctx, newClient("", pr), newRequest(t))
+
+		go func() { _, _ = pw.Write([]byte("{\"_id\":1}\n")) }()
+		assert.Equal(t, sampleObject{Id: 1}, <-outStream)
+
+		cancel()
+		select {
+		case _, ok := <-outStream:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("expected the stream to close once ctx was cancelled")
+		}
+		assert.ErrorIs(t, <-errStream, context.Canceled)
+		_, err := pw.Write([]byte("more"))
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+
+	t.Run("when used as the source of a FanOut, we should process every item", func(t *testing.T) {
+		body := io.NopCloser(strings.NewReader("{\"_id\":1}\n{\"_id\":2}\n{\"_id\":3}\n"))
+		ctx := context.Background()
+		outStream, _ := HttpStream[sampleObject](ctx, newClient("", body), newRequest(t))
+		var getID WorkerFunc[sampleObject, int] = func(ctx context.Context, o sampleObject) int { return o.Id }
+		expectStreamLengthToBe(3, FanIn(ctx, FanOut(ctx, outStream, 2, getID)), t)
+	})
+}
@@ -0,0 +1,268 @@
+package pipelines
+
+import (
+	"context"
+	"time"
+)
+
+type JoinKind int
+
+const (
+	// InnerJoin sends only the left and right items that match.
+	InnerJoin JoinKind = iota
+	// LeftJoin also sends each left item that finds no match, once it is dropped from the join, with Matched unset.
+	LeftJoin
+)
+
+// Joined is a left item paired with a right item that shares its key.
+type Joined[L, R any] struct {
+	Left  L
+	Right R
+	// Matched is unset for a left item that found no match in a LeftJoin, whose Right is then the zero value.
+	Matched bool
+}
+
+type JoinOptions struct {
+	Kind JoinKind
+	// Window is how long an item is held for items from the other stream to be matched with. When zero, items are
+	// held until MaxBuffered pushes them out.
+	Window time.Duration
+	// MaxBuffered is the most items held from each stream, the oldest being dropped to make room.
+	MaxBuffered int
+
+	Clock Clock
+}
+
+type JoinOption func(*JoinOptions)
+
+// Pair holds an item from each of the streams passed to Zip.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Join matches the items of left and right by the keys that leftKey and rightKey give them. Each item that arrives is
+// paired with every held item from the other stream with the same key, and then held itself, until the Window passes
+// or MaxBuffered items have arrived after it from its own stream.
+//
+// As with Combine, a nil stream is treated as an empty one. The out stream is closed once both streams are closed, or
+// ctx is cancelled.
+func Join[L, R any, K comparable](ctx context.Context, left <-chan L, right <-chan R, leftKey func(L) K, rightKey func(R) K, options ...JoinOption) <-chan Joined[L, R] {
+	if leftKey == nil {
+		panic("Join: leftKey arg has nil value")
+	}
+
+	if rightKey == nil {
+		panic("Join: rightKey arg has nil value")
+	}
+
+	ops := JoinOptions{
+		Kind:        InnerJoin,
+		MaxBuffered: 1000,
+		Clock:       RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	if ops.MaxBuffered < 1 {
+		ops.MaxBuffered = 1
+	}
+
+	outStream := make(chan Joined[L, R])
+
+	go func() {
+		defer close(outStream)
+
+		lefts := joinSide[L, K]{byKey: make(map[K][]*joinEntry[L, K])}
+		rights := joinSide[R, K]{byKey: make(map[K][]*joinEntry[R, K])}
+
+		// dropLeft lets go of the oldest left item, sending it unmatched if need be
+		dropLeft := func() bool {
+			entry := lefts.drop()
+			if ops.Kind == LeftJoin && !entry.matched {
+				return sendOrDone(ctx, outStream, Joined[L, R]{Left: entry.item})
+			}
+			return true
+		}
+
+		expire := func() bool {
+			if ops.Window <= 0 {
+				return true
+			}
+			cutoff := ops.Clock.Now().Add(-ops.Window)
+			for len(lefts.queue) > 0 && !lefts.queue[0].at.After(cutoff) {
+				if !dropLeft() {
+					return false
+				}
+			}
+			for len(rights.queue) > 0 && !rights.queue[0].at.After(cutoff) {
+				rights.drop()
+			}
+			return true
+		}
+
+		var timer Timer
+		var timerSet bool
+		if ops.Window > 0 {
+			timer = ops.Clock.NewTimer(ops.Window)
+			timer.Stop()
+			defer timer.Stop()
+		}
+		schedule := func() {
+			if timer == nil {
+				return
+			}
+			var next time.Time
+			if len(lefts.queue) > 0 {
+				next = lefts.queue[0].at
+			}
+			if len(rights.queue) > 0 && (next.IsZero() || rights.queue[0].at.Before(next)) {
+				next = rights.queue[0].at
+			}
+			timerSet = !next.IsZero()
+			if timerSet {
+				resetTimer(timer, next.Add(ops.Window).Sub(ops.Clock.Now()))
+			}
+		}
+
+		for left != nil || right != nil {
+			var timerC <-chan time.Time
+			if timerSet {
+				timerC = timer.C()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case l, ok := <-left:
+				if !ok {
+					left = nil
+					continue
+				}
+				if !expire() {
+					return
+				}
+				entry := &joinEntry[L, K]{item: l, key: leftKey(l), at: ops.Clock.Now()}
+				for _, r := range rights.byKey[entry.key] {
+					entry.matched = true
+					if !sendOrDone(ctx, outStream, Joined[L, R]{Left: l, Right: r.item, Matched: true}) {
+						return
+					}
+				}
+				lefts.push(entry)
+				if len(lefts.queue) > ops.MaxBuffered && !dropLeft() {
+					return
+				}
+			case r, ok := <-right:
+				if !ok {
+					right = nil
+					continue
+				}
+				if !expire() {
+					return
+				}
+				entry := &joinEntry[R, K]{item: r, key: rightKey(r), at: ops.Clock.Now()}
+				for _, l := range lefts.byKey[entry.key] {
+					l.matched = true
+					if !sendOrDone(ctx, outStream, Joined[L, R]{Left: l.item, Right: r, Matched: true}) {
+						return
+					}
+				}
+				rights.push(entry)
+				if len(rights.queue) > ops.MaxBuffered {
+					rights.drop()
+				}
+			case <-timerC:
+				timerSet = false
+				if !expire() {
+					return
+				}
+			}
+			schedule()
+		}
+
+		for len(lefts.queue) > 0 {
+			if !dropLeft() {
+				return
+			}
+		}
+	}()
+
+	return outStream
+}
+
+type joinEntry[T any, K comparable] struct {
+	item    T
+	key     K
+	at      time.Time
+	matched bool
+}
+
+// joinSide holds the items of one of Join's streams in the order they arrived, and by key.
+type joinSide[T any, K comparable] struct {
+	queue []*joinEntry[T, K]
+	byKey map[K][]*joinEntry[T, K]
+}
+
+func (s *joinSide[T, K]) push(entry *joinEntry[T, K]) {
+	s.queue = append(s.queue, entry)
+	s.byKey[entry.key] = append(s.byKey[entry.key], entry)
+}
+
+// drop removes the oldest entry, which is also the oldest for its key.
+func (s *joinSide[T, K]) drop() *joinEntry[T, K] {
+	entry := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	sameKey := s.byKey[entry.key]
+	sameKey[0] = nil
+	if len(sameKey) == 1 {
+		delete(s.byKey, entry.key)
+	} else {
+		s.byKey[entry.key] = sameKey[1:]
+	}
+	return entry
+}
+
+// Zip pairs the items of a and b in the order they arrive, the first of a with the first of b and so on. The out
+// stream is closed once either stream is closed, or ctx is cancelled. As with Combine, a nil stream is treated as an
+// empty one, so zipping with it gives an empty stream.
+func Zip[A, B any](ctx context.Context, a <-chan A, b <-chan B) <-chan Pair[A, B] {
+	outStream := make(chan Pair[A, B])
+
+	go func() {
+		defer close(outStream)
+		if a == nil || b == nil {
+			return
+		}
+
+		for {
+			var pair Pair[A, B]
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case pair.First, ok = <-a:
+				if !ok {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case pair.Second, ok = <-b:
+				if !ok {
+					return
+				}
+			}
+			if !sendOrDone(ctx, outStream, pair) {
+				return
+			}
+		}
+	}()
+
+	return outStream
+}
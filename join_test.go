@@ -0,0 +1,164 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type deviceStatus struct {
+	Device string
+	Status string
+}
+
+type configChange struct {
+	Device  string
+	Setting string
+}
+
+func TestJoin(t *testing.T) {
+	statusKey := func(s deviceStatus) string { return s.Device }
+	changeKey := func(c configChange) string { return c.Device }
+
+	// collect reads out in the background, so that the test can feed the join one item at a time
+	collect := func(out <-chan Joined[deviceStatus, configChange]) <-chan []Joined[deviceStatus, configChange] {
+		res := make(chan []Joined[deviceStatus, configChange], 1)
+		go func() {
+			var all []Joined[deviceStatus, configChange]
+			for j := range out {
+				all = append(all, j)
+			}
+			res <- all
+		}()
+		return res
+	}
+
+	t.Run("when any of the key funcs have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Join[deviceStatus, configChange, string](context.Background(), nil, nil, nil, changeKey)
+		})
+		assert.Panics(t, func() {
+			Join[deviceStatus, configChange, string](context.Background(), nil, nil, statusKey, nil)
+		})
+	})
+
+	t.Run("when items share a key, they should be paired whichever arrives first", func(t *testing.T) {
+		left := make(chan deviceStatus)
+		right := make(chan configChange)
+		res := collect(Join(context.Background(), left, right, statusKey, changeKey))
+
+		left <- deviceStatus{"desk-1", "online"}
+		left <- deviceStatus{"desk-2", "online"}
+		right <- configChange{"desk-1", "gain"}
+		right <- configChange{"desk-3", "mute"}
+		left <- deviceStatus{"desk-3", "offline"}
+		close(left)
+		close(right)
+
+		assert.Equal(t, []Joined[deviceStatus, configChange]{
+			{Left: deviceStatus{"desk-1", "online"}, Right: configChange{"desk-1", "gain"}, Matched: true},
+			{Left: deviceStatus{"desk-3", "offline"}, Right: configChange{"desk-3", "mute"}, Matched: true},
+		}, <-res)
+	})
+
+	t.Run("when several items share a key, each should be paired with every match", func(t *testing.T) {
+		left := make(chan deviceStatus)
+		right := make(chan configChange)
+		res := collect(Join(context.Background(), left, right, statusKey, changeKey))
+
+		left <- deviceStatus{"desk-1", "online"}
+		left <- deviceStatus{"desk-1", "busy"}
+		right <- configChange{"desk-1", "gain"}
+		close(left)
+		close(right)
+
+		assert.Len(t, <-res, 2)
+	})
+
+	t.Run("when it is a LeftJoin, left items without a match should be sent unmatched", func(t *testing.T) {
+		left := make(chan deviceStatus)
+		right := make(chan configChange)
+		res := collect(Join(context.Background(), left, right, statusKey, changeKey, func(o *JoinOptions) {
+			o.Kind = LeftJoin
+			o.MaxBuffered = 1
+		}))
+
+		left <- deviceStatus{"desk-1", "online"}
+		right <- configChange{"desk-1", "gain"}
+		// pushes desk-1 out of the buffer, but it has been matched already
+		left <- deviceStatus{"desk-2", "online"}
+		// pushes desk-2 out of the buffer unmatched
+		left <- deviceStatus{"desk-3", "online"}
+		close(left)
+		close(right)
+
+		assert.Equal(t, []Joined[deviceStatus, configChange]{
+			{Left: deviceStatus{"desk-1", "online"}, Right: configChange{"desk-1", "gain"}, Matched: true},
+			{Left: deviceStatus{"desk-2", "online"}},
+			{Left: deviceStatus{"desk-3", "online"}},
+		}, <-res)
+	})
+
+	t.Run("when an item has been held for longer than the Window, it should no longer be matched", func(t *testing.T) {
+		clock := newFakeClock()
+		left := make(chan deviceStatus)
+		right := make(chan configChange)
+		out := Join(context.Background(), left, right, statusKey, changeKey, func(o *JoinOptions) {
+			o.Kind = LeftJoin
+			o.Window = time.Minute
+			o.Clock = clock
+		})
+
+		left <- deviceStatus{"desk-1", "online"}
+		assert.Equal(t, Joined[deviceStatus, configChange]{Left: deviceStatus{"desk-1", "online"}},
+			receiveAfterAdvancing(t, clock, time.Minute, out))
+
+		res := collect(out)
+		right <- configChange{"desk-1", "gain"}
+		close(left)
+		close(right)
+		assert.Empty(t, <-res)
+	})
+
+	t.Run("when a stream has a nil value, it should be treated as empty", func(t *testing.T) {
+		res := collect(Join(context.Background(), GenerateFromSlice(context.Background(), []deviceStatus{{"desk-1", "online"}}),
+			nil, statusKey, changeKey, func(o *JoinOptions) { o.Kind = LeftJoin }))
+
+		assert.Equal(t, []Joined[deviceStatus, configChange]{{Left: deviceStatus{"desk-1", "online"}}}, <-res)
+	})
+
+	t.Run("when ctx is cancelled, the out stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Join(ctx, make(chan deviceStatus), make(chan configChange), statusKey, changeKey)
+		cancel()
+		expectClosedChannel(true, out, t)
+	})
+}
+
+func TestZip(t *testing.T) {
+	t.Run("when both streams have items, they should be paired in order until either closes", func(t *testing.T) {
+		ctx := context.Background()
+		out := Zip(ctx, GenerateFromSlice(ctx, []int{1, 2, 3}), GenerateFromSlice(ctx, []string{"a", "b"}))
+
+		var pairs []Pair[int, string]
+		for p := range out {
+			pairs = append(pairs, p)
+		}
+		assert.Equal(t, []Pair[int, string]{{1, "a"}, {2, "b"}}, pairs)
+	})
+
+	t.Run("when a stream has a nil value, we should receive an empty closed stream", func(t *testing.T) {
+		ctx := context.Background()
+		out := Zip[int, string](ctx, GenerateFromSlice(ctx, []int{1}), nil)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when ctx is cancelled, the out stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Zip(ctx, make(chan int), make(chan string))
+		cancel()
+		expectClosedChannel(true, out, t)
+	})
+}
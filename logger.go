@@ -0,0 +1,138 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the structured logger used by the stages in this package. keyvals are alternating keys and values,
+// in the same manner as log/slog.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+	// With returns a Logger that attaches keyvals to everything it logs.
+	With(keyvals ...interface{}) Logger
+}
+
+type loggerCtxKey struct{}
+
+// WithLogger returns a copy of ctx that causes every stage it is passed to to log through l.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// LoggerFromContext returns the Logger attached to ctx, or a Logger that discards everything.
+func LoggerFromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	return NopLogger()
+}
+
+// WithPipelineName returns a copy of ctx whose Logger identifies everything it logs as belonging to the named pipeline.
+func WithPipelineName(ctx context.Context, name string) context.Context {
+	return WithLogger(ctx, LoggerFromContext(ctx).With("pipeline", name))
+}
+
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+func (l nopLogger) With(...interface{}) Logger { return l }
+
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		panic("NewSlogLogger: l arg has nil value")
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct{ l *slog.Logger }
+
+func (s slogLogger) Debug(msg string, keyvals ...interface{}) { s.l.Debug(msg, keyvals...) }
+func (s slogLogger) Info(msg string, keyvals ...interface{})  { s.l.Info(msg, keyvals...) }
+func (s slogLogger) Error(msg string, keyvals ...interface{}) { s.l.Error(msg, keyvals...) }
+func (s slogLogger) With(keyvals ...interface{}) Logger       { return slogLogger{s.l.With(keyvals...)} }
+
+func NewLogrusLogger(l logrus.FieldLogger) Logger {
+	if l == nil {
+		panic("NewLogrusLogger: l arg has nil value")
+	}
+	return logrusLogger{l}
+}
+
+type logrusLogger struct{ l logrus.FieldLogger }
+
+func (s logrusLogger) Debug(msg string, keyvals ...interface{}) {
+	s.l.WithFields(fieldsOf(keyvals)).Debug(msg)
+}
+
+func (s logrusLogger) Info(msg string, keyvals ...interface{}) {
+	s.l.WithFields(fieldsOf(keyvals)).Info(msg)
+}
+
+func (s logrusLogger) Error(msg string, keyvals ...interface{}) {
+	s.l.WithFields(fieldsOf(keyvals)).Error(msg)
+}
+
+func (s logrusLogger) With(keyvals ...interface{}) Logger {
+	return logrusLogger{s.l.WithFields(fieldsOf(keyvals))}
+}
+
+func fieldsOf(keyvals []interface{}) logrus.Fields {
+	fields := make(logrus.Fields, len(keyvals)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fields["!BADKEY"] = keyvals[i]
+			break
+		}
+		fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	return fields
+}
+
+// printfLogger adapts a HeartbeatsLogger to the Logger interface, appending the keyvals to each message.
+type printfLogger struct {
+	l       HeartbeatsLogger
+	keyvals []interface{}
+}
+
+func (p printfLogger) Debug(msg string, keyvals ...interface{}) {
+	p.l.Debugf("%s", p.format(msg, keyvals))
+}
+
+func (p printfLogger) Info(msg string, keyvals ...interface{}) {
+	p.l.Infof("%s", p.format(msg, keyvals))
+}
+
+func (p printfLogger) Error(msg string, keyvals ...interface{}) {
+	p.l.Errorf("%s", p.format(msg, keyvals))
+}
+
+func (p printfLogger) With(keyvals ...interface{}) Logger {
+	return printfLogger{l: p.l, keyvals: append(append([]interface{}(nil), p.keyvals...), keyvals...)}
+}
+
+func (p printfLogger) format(msg string, keyvals []interface{}) string {
+	all := append(append([]interface{}(nil), p.keyvals...), keyvals...)
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i < len(all); i += 2 {
+		if i+1 == len(all) {
+			fmt.Fprintf(&sb, " !BADKEY=%v", all[i])
+			break
+		}
+		fmt.Fprintf(&sb, " %v=%v", all[i], all[i+1])
+	}
+	return sb.String()
+}
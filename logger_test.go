@@ -0,0 +1,149 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	t.Run("when no logger has been attached, we should receive a logger that discards everything", func(t *testing.T) {
+		assert.Equal(t, NopLogger(), LoggerFromContext(context.Background()))
+	})
+
+	t.Run("when we name the pipeline, everything logged should carry the name", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		ctx := WithLogger(context.Background(), NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+		LoggerFromContext(WithPipelineName(ctx, "orders")).Info("hello")
+
+		line := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "orders", line["pipeline"])
+	})
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	t.Run("when l has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewSlogLogger(nil) })
+	})
+
+	t.Run("when we log with fields, we should see them as slog attributes", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l := NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+		l.With("stage", "FanOut").Error("failed", "worker", 2)
+
+		line := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "failed", line["msg"])
+		assert.Equal(t, "ERROR", line["level"])
+		assert.Equal(t, "FanOut", line["stage"])
+		assert.Equal(t, float64(2), line["worker"])
+	})
+}
+
+func TestNewLogrusLogger(t *testing.T) {
+	t.Run("when l has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewLogrusLogger(nil) })
+	})
+
+	t.Run("when we log with fields, we should see them as logrus fields", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		ll := logrus.New()
+		ll.SetOutput(buf)
+		ll.SetFormatter(&logrus.JSONFormatter{})
+		l := NewLogrusLogger(ll)
+		l.With("stage", "FanOut").Info("started", "worker", 2, "dangling")
+
+		line := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "started", line["msg"])
+		assert.Equal(t, "info", line["level"])
+		assert.Equal(t, "FanOut", line["stage"])
+		assert.Equal(t, float64(2), line["worker"])
+		assert.Equal(t, "dangling", line["!BADKEY"])
+	})
+}
+
+func TestStageLogging(t *testing.T) {
+	t.Run("when FanOut workers run, they should log through the ctx logger with their stage and worker id", func(t *testing.T) {
+		l := newRecordingLogger()
+		ctx := WithLogger(context.Background(), l)
+		var pipeFunc WorkerFunc[string, string] = func(ctx context.Context, item string) string { return item }
+
+		expectStreamLengthToBe(1, FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, []string{"hello"}), 2, pipeFunc)), t)
+
+		assert.Contains(t, l.lines(), "DEBUG worker started stage=FanOut worker=0")
+		assert.Contains(t, l.lines(), "DEBUG worker started stage=FanOut worker=1")
+	})
+
+	t.Run("when DoWorkWithHeartbeats completes, it should log through the ctx logger with its task name", func(t *testing.T) {
+		l := newRecordingLogger()
+		ctx := WithLogger(context.Background(), l)
+
+		_, err := DoWorkWithHeartbeats(ctx, func(ctx context.Context) int { return 1 }, func(ho *HeartbeatsOptions) {
+			ho.TaskName = "sum"
+		})
+		require.NoError(t, err)
+		assert.Contains(t, l.lines(), "INFO task completed stage=DoWorkWithHeartbeats task=sum")
+	})
+
+	t.Run("when DoWorkWithHeartbeats is given a printf style logger, it should still be used", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		ll := logrus.New()
+		ll.SetOutput(buf)
+
+		_, err := DoWorkWithHeartbeats(context.Background(), func(ctx context.Context) int { return 1 }, func(ho *HeartbeatsOptions) {
+			ho.Logger = ll
+			ho.PulseInterval = time.Millisecond * 100
+		})
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "task completed stage=DoWorkWithHeartbeats")
+	})
+}
+
+// recordingLogger keeps every line it is asked to log, formatted as "LEVEL msg key=val ...".
+type recordingLogger struct {
+	mu      *sync.Mutex
+	out     *[]string
+	keyvals []interface{}
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mu: &sync.Mutex{}, out: &[]string{}}
+}
+
+func (r *recordingLogger) log(level, msg string, keyvals []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(level + " " + msg)
+	all := append(append([]interface{}(nil), r.keyvals...), keyvals...)
+	for i := 0; i+1 < len(all); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", all[i], all[i+1])
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.out = append(*r.out, sb.String())
+}
+
+func (r *recordingLogger) Debug(msg string, keyvals ...interface{}) { r.log("DEBUG", msg, keyvals) }
+func (r *recordingLogger) Info(msg string, keyvals ...interface{})  { r.log("INFO", msg, keyvals) }
+func (r *recordingLogger) Error(msg string, keyvals ...interface{}) { r.log("ERROR", msg, keyvals) }
+
+func (r *recordingLogger) With(keyvals ...interface{}) Logger {
+	return &recordingLogger{mu: r.mu, out: r.out, keyvals: append(append([]interface{}(nil), r.keyvals...), keyvals...)}
+}
+
+func (r *recordingLogger) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), *r.out...)
+}
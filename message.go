@@ -0,0 +1,275 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAckTimeout is the reason given for a message that wasn't acknowledged within the AckTimeout.
+	ErrAckTimeout = errors.New("message not acknowledged in time")
+	errNacked     = errors.New("message nacked")
+)
+
+// Message is an item from an AckSource, which is only done with once it is acknowledged. A message that is nacked, or
+// not acknowledged in time, is delivered again. Messages are passed by value, so they can flow through WorkerThread,
+// FanOut, FanIn and Combine like any other item, and any copy may acknowledge it. Only the first Ack or Nack counts.
+type Message[T any] struct {
+	Body T
+	// Attempt is how many times the message has been delivered, starting at 1.
+	Attempt int
+
+	delivery *delivery
+}
+
+type delivery struct {
+	id      uint64
+	settled sync.Once
+	acks    chan<- ackEvent
+	done    <-chan struct{}
+}
+
+type ackEvent struct {
+	id  uint64
+	err error
+}
+
+// Ack marks the message as done.
+func (m Message[T]) Ack() {
+	m.settle(nil)
+}
+
+// Nack marks the message as failed, so that it is delivered again. err is recorded should it be given up on.
+func (m Message[T]) Nack(err error) {
+	if err == nil {
+		err = errNacked
+	}
+	m.settle(err)
+}
+
+func (m Message[T]) settle(err error) {
+	d := m.delivery
+	if d == nil {
+		return
+	}
+	d.settled.Do(func() {
+		select {
+		case d.acks <- ackEvent{id: d.id, err: err}:
+		case <-d.done:
+		}
+	})
+}
+
+// WithMessage adapts workerFunc to take and return Messages, so that each result can still acknowledge the message
+// it came from.
+func WithMessage[In, Out any](workerFunc WorkerFunc[In, Out]) WorkerFunc[Message[In], Message[Out]] {
+	if workerFunc == nil {
+		panic("WithMessage: workerFunc arg has nil value")
+	}
+
+	return func(ctx context.Context, in Message[In]) Message[Out] {
+		return Message[Out]{Body: workerFunc(ctx, in.Body), Attempt: in.Attempt, delivery: in.delivery}
+	}
+}
+
+type AckSourceOptions[T any] struct {
+	// MaxInFlight is how many messages may be delivered without yet being acknowledged. It defaults to 100.
+	MaxInFlight int
+	// AckTimeout is how long a message may go unacknowledged before it is delivered again. It defaults to 30s.
+	AckTimeout time.Duration
+	// MaxDeliveries is how many times a message is delivered before it is given up on. Zero means no limit.
+	MaxDeliveries int
+	// DeadLetters, when set, takes the messages given up on. Otherwise they are logged to the ctx Logger.
+	DeadLetters DeadLetterSink[T]
+
+	Clock Clock
+}
+
+type AckSourceOption[T any] func(*AckSourceOptions[T])
+
+type AckSourceStats struct {
+	InFlight    int
+	Acked       uint64
+	Redelivered uint64
+	// DeadLettered is the number of messages given up on after MaxDeliveries.
+	DeadLettered uint64
+}
+
+// AckSource delivers the items read from a stream as Messages, with at-least-once semantics: each is delivered again
+// until it is acknowledged, or until it has been delivered MaxDeliveries times.
+type AckSource[T any] struct {
+	ops       AckSourceOptions[T]
+	outStream chan Message[T]
+	acks      chan ackEvent
+	done      chan struct{}
+
+	mu    sync.Mutex
+	stats AckSourceStats
+}
+
+type pendingMessage[T any] struct {
+	body     T
+	attempt  int
+	deadline time.Time
+}
+
+// NewAckSource reads items from in and delivers them as Messages on its Out stream. Out is closed once in is closed
+// and every message has been acknowledged or given up on, or once ctx is cancelled.
+func NewAckSource[T any](ctx context.Context, in <-chan T, options ...AckSourceOption[T]) *AckSource[T] {
+	if in == nil {
+		panic("NewAckSource: in arg has nil value")
+	}
+
+	ops := AckSourceOptions[T]{
+		MaxInFlight: 100,
+		AckTimeout:  time.Second * 30,
+		Clock:       RealClock(),
+	}
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+	if ops.MaxInFlight < 1 {
+		ops.MaxInFlight = 1
+	}
+
+	s := &AckSource[T]{
+		ops:       ops,
+		outStream: make(chan Message[T]),
+		acks:      make(chan ackEvent),
+		done:      make(chan struct{}),
+	}
+	go s.run(ctx, in)
+	return s
+}
+
+func (s *AckSource[T]) Out() <-chan Message[T] {
+	return s.outStream
+}
+
+func (s *AckSource[T]) Stats() AckSourceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *AckSource[T]) run(ctx context.Context, in <-chan T) {
+	defer close(s.outStream)
+	defer close(s.done)
+
+	observer := ObserverFromContext(ctx)
+	logger := LoggerFromContext(ctx).With("stage", "AckSource")
+
+	var (
+		nextID   uint64
+		inFlight = make(map[uint64]pendingMessage[T])
+		queue    []pendingMessage[T]
+	)
+
+	timer := s.ops.Clock.NewTimer(s.ops.AckTimeout)
+	timer.Stop()
+	defer timer.Stop()
+
+	// retry puts a message that failed back on the queue, or gives up on it
+	retry := func(p pendingMessage[T], err error) {
+		if s.ops.MaxDeliveries > 0 && p.attempt >= s.ops.MaxDeliveries {
+			s.mu.Lock()
+			s.stats.DeadLettered++
+			s.mu.Unlock()
+			if s.ops.DeadLetters == nil {
+				logger.Error("message given up on", "attempts", p.attempt, "error", err)
+				return
+			}
+			letter := DeadLetter[T]{Item: p.body, Err: err, Attempts: p.attempt, Time: s.ops.Clock.Now()}
+			if sinkErr := s.ops.DeadLetters.Put(context.WithoutCancel(ctx), letter); sinkErr != nil {
+				logger.Error("failed to put dead letter", "error", sinkErr, "cause", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		s.stats.Redelivered++
+		s.mu.Unlock()
+		queue = append(queue, pendingMessage[T]{body: p.body, attempt: p.attempt + 1})
+	}
+
+	for {
+		if in == nil && len(queue) == 0 && len(inFlight) == 0 {
+			return
+		}
+
+		var (
+			recv <-chan T
+			send chan Message[T]
+			msg  Message[T]
+		)
+		if len(inFlight) < s.ops.MaxInFlight {
+			if len(queue) > 0 {
+				send = s.outStream
+				msg = Message[T]{
+					Body:     queue[0].body,
+					Attempt:  queue[0].attempt,
+					delivery: &delivery{id: nextID, acks: s.acks, done: s.done},
+				}
+			} else {
+				recv = in
+			}
+		}
+
+		var timeout <-chan time.Time
+		if len(inFlight) > 0 {
+			var earliest time.Time
+			for _, p := range inFlight {
+				if earliest.IsZero() || p.deadline.Before(earliest) {
+					earliest = p.deadline
+				}
+			}
+			resetTimer(timer, earliest.Sub(s.ops.Clock.Now()))
+			timeout = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			observer.Cancelled("AckSource")
+			return
+		case item, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			observer.ItemIn("AckSource")
+			queue = append(queue, pendingMessage[T]{body: item, attempt: 1})
+		case send <- msg:
+			observer.ItemOut("AckSource")
+			p := queue[0]
+			queue = queue[1:]
+			p.deadline = s.ops.Clock.Now().Add(s.ops.AckTimeout)
+			inFlight[nextID] = p
+			nextID++
+		case ev := <-s.acks:
+			// a message missing from inFlight has already timed out and been queued again
+			if p, ok := inFlight[ev.id]; ok {
+				delete(inFlight, ev.id)
+				if ev.err != nil {
+					retry(p, ev.err)
+				} else {
+					s.mu.Lock()
+					s.stats.Acked++
+					s.mu.Unlock()
+				}
+			}
+		case <-timeout:
+			now := s.ops.Clock.Now()
+			for id, p := range inFlight {
+				if !p.deadline.After(now) {
+					delete(inFlight, id)
+					retry(p, ErrAckTimeout)
+				}
+			}
+		}
+
+		s.mu.Lock()
+		s.stats.InFlight = len(inFlight)
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,124 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAckSource(t *testing.T) {
+	double := func(ctx context.Context, in int) int { return in * 2 }
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewAckSource[int](context.Background(), nil) })
+		assert.Panics(t, func() { WithMessage[int, int](nil) })
+	})
+
+	t.Run("when messages flow through FanOut, FanIn and Combine and are acked, Out should close", func(t *testing.T) {
+		ctx := context.Background()
+		first := NewAckSource(ctx, GenerateFromSlice(ctx, []int{1, 2, 3}))
+		second := NewAckSource(ctx, GenerateFromSlice(ctx, []int{4}))
+		messages := Combine(ctx, first.Out(), second.Out())
+
+		var got []int
+		// FanIn reads each worker's stream in turn, so a second worker's messages couldn't be acked until the first's
+		// stream closed, which waits on every message being acked
+		for msg := range FanIn(ctx, FanOut(ctx, messages, 1, WithMessage(double))) {
+			got = append(got, msg.Body)
+			assert.Equal(t, 1, msg.Attempt)
+			msg.Ack()
+		}
+		sort.Ints(got)
+		assert.Equal(t, []int{2, 4, 6, 8}, got)
+		assert.Equal(t, AckSourceStats{Acked: 3}, first.Stats())
+	})
+
+	t.Run("when a message is nacked, it should be delivered again", func(t *testing.T) {
+		ctx := context.Background()
+		source := NewAckSource(ctx, GenerateFromSlice(ctx, []string{"fader"}))
+
+		msg := <-source.Out()
+		msg.Nack(errors.New("device busy"))
+		msg.Ack()
+
+		msg = <-source.Out()
+		assert.Equal(t, "fader", msg.Body)
+		assert.Equal(t, 2, msg.Attempt)
+		msg.Ack()
+		expectClosedChannel(true, source.Out(), t)
+		assert.Equal(t, uint64(1), source.Stats().Redelivered)
+	})
+
+	t.Run("when a message isn't acked within AckTimeout, it should be delivered again and the late ack ignored", func(t *testing.T) {
+		ctx := context.Background()
+		clock := newFakeClock()
+		source := NewAckSource(ctx, GenerateFromSlice(ctx, []string{"fader"}), func(ops *AckSourceOptions[string]) {
+			ops.AckTimeout = time.Second
+			ops.Clock = clock
+		})
+
+		late := <-source.Out()
+		redelivered := receiveAfterAdvancing(t, clock, time.Second, source.Out())
+		assert.Equal(t, 2, redelivered.Attempt)
+
+		late.Ack()
+		assert.Equal(t, uint64(0), source.Stats().Acked)
+		expectNothingFor(t, source.Out())
+
+		redelivered.Ack()
+		expectClosedChannel(true, source.Out(), t)
+		assert.Equal(t, uint64(1), source.Stats().Acked)
+	})
+
+	t.Run("when MaxInFlight messages are unacked, no more should be delivered until one is", func(t *testing.T) {
+		ctx := context.Background()
+		source := NewAckSource(ctx, GenerateFromSlice(ctx, []int{1, 2, 3}), func(ops *AckSourceOptions[int]) {
+			ops.MaxInFlight = 2
+		})
+
+		first := <-source.Out()
+		<-source.Out()
+		expectNothingFor(t, source.Out())
+		assert.Equal(t, 2, source.Stats().InFlight)
+
+		first.Ack()
+		assert.Equal(t, 3, (<-source.Out()).Body)
+	})
+
+	t.Run("when a message reaches MaxDeliveries, it should be given up on and put in DeadLetters", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+		errRejected := errors.New("rejected")
+		source := NewAckSource(ctx, GenerateFromSlice(ctx, []int{7}), func(ops *AckSourceOptions[int]) {
+			ops.MaxDeliveries = 2
+			ops.DeadLetters = sink
+		})
+
+		for msg := range source.Out() {
+			msg.Nack(errRejected)
+		}
+
+		letters, err := sink.DeadLetters()
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		assert.Equal(t, 7, letters[0].Item)
+		assert.Equal(t, 2, letters[0].Attempts)
+		assert.ErrorIs(t, letters[0].Err, errRejected)
+		assert.Equal(t, uint64(1), source.Stats().DeadLettered)
+	})
+
+	t.Run("when ctx is cancelled, Out should close and acks shouldn't block", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		source := NewAckSource(ctx, GenerateFromSlice(context.Background(), []int{1, 2}))
+		msg := <-source.Out()
+		cancel()
+		for range source.Out() {
+		}
+		msg.Ack()
+	})
+}
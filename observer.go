@@ -0,0 +1,164 @@
+package pipelines
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Observer is notified by the stages in this package as items flow through them. Every call is labelled with the
+// name of the stage making it e.g. "FanOut", "WorkerThread", "FanIn", "Combine", "TeeSplitter" or "HttpReqAsync".
+// Implementations must be safe for concurrent use.
+type Observer interface {
+	ItemIn(stage string)
+	ItemOut(stage string)
+	// WorkerLatency is the time taken to process a single item.
+	WorkerLatency(stage string, d time.Duration)
+	// BlockedSend is the time a stage spent waiting for a downstream reader to take an item.
+	BlockedSend(stage string, d time.Duration)
+	Cancelled(stage string)
+}
+
+type observerCtxKey struct{}
+
+// WithObserver returns a copy of ctx that causes every stage it is passed to to report to o.
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	return context.WithValue(ctx, observerCtxKey{}, o)
+}
+
+// ObserverFromContext returns the Observer attached to ctx, or an Observer that does nothing.
+func ObserverFromContext(ctx context.Context) Observer {
+	if o, ok := ctx.Value(observerCtxKey{}).(Observer); ok && o != nil {
+		return o
+	}
+	return nopObserver{}
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ItemIn(string)                       {}
+func (nopObserver) ItemOut(string)                      {}
+func (nopObserver) WorkerLatency(string, time.Duration) {}
+func (nopObserver) BlockedSend(string, time.Duration)   {}
+func (nopObserver) Cancelled(string)                    {}
+
+// DefaultLatencyBuckets are the histogram upper bounds, in seconds, used by NewMemoryObserver when none are given.
+var DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Histogram struct {
+	// Buckets holds the upper bound, in seconds, of each bucket and Counts the cumulative number of observations
+	// less than or equal to it.
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+func newHistogram(buckets []float64) Histogram {
+	return Histogram{
+		Buckets: buckets,
+		Counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	secs := d.Seconds()
+	for i, le := range h.Buckets {
+		if secs <= le {
+			h.Counts[i]++
+		}
+	}
+	h.Sum += secs
+	h.Count++
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+type StageMetrics struct {
+	ItemsIn       uint64
+	ItemsOut      uint64
+	Cancellations uint64
+	WorkerLatency Histogram
+	BlockedSend   Histogram
+}
+
+// MemoryObserver is an Observer that keeps its metrics in memory, grouped by stage.
+type MemoryObserver struct {
+	mu      sync.Mutex
+	buckets []float64
+	stages  map[string]*StageMetrics
+}
+
+func NewMemoryObserver(buckets ...float64) *MemoryObserver {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &MemoryObserver{
+		buckets: buckets,
+		stages:  make(map[string]*StageMetrics),
+	}
+}
+
+// stage must be called with m.mu held.
+func (m *MemoryObserver) stage(name string) *StageMetrics {
+	s, ok := m.stages[name]
+	if !ok {
+		s = &StageMetrics{
+			WorkerLatency: newHistogram(m.buckets),
+			BlockedSend:   newHistogram(m.buckets),
+		}
+		m.stages[name] = s
+	}
+	return s
+}
+
+func (m *MemoryObserver) ItemIn(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).ItemsIn++
+}
+
+func (m *MemoryObserver) ItemOut(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).ItemsOut++
+}
+
+func (m *MemoryObserver) WorkerLatency(stage string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).WorkerLatency.observe(d)
+}
+
+func (m *MemoryObserver) BlockedSend(stage string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).BlockedSend.observe(d)
+}
+
+func (m *MemoryObserver) Cancelled(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).Cancellations++
+}
+
+// Snapshot returns a copy of the metrics recorded so far, keyed by stage.
+func (m *MemoryObserver) Snapshot() map[string]StageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]StageMetrics, len(m.stages))
+	for name, s := range m.stages {
+		c := *s
+		c.WorkerLatency = s.WorkerLatency.clone()
+		c.BlockedSend = s.BlockedSend.clone()
+		out[name] = c
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package pipelines
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// NewPrometheusHandler returns a http.Handler that serves the metrics held by m in the Prometheus text exposition format.
+func NewPrometheusHandler(m *MemoryObserver) http.Handler {
+	if m == nil {
+		panic("NewPrometheusHandler: m arg has nil value")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WritePrometheus(w, m)
+	})
+}
+
+// WritePrometheus writes the metrics held by m to w in the Prometheus text exposition format.
+func WritePrometheus(w io.Writer, m *MemoryObserver) error {
+	snapshot := m.Snapshot()
+	stages := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		stages = append(stages, name)
+	}
+	sort.Strings(stages)
+
+	bw := bufio.NewWriter(w)
+
+	counters := []struct {
+		name, help string
+		value      func(StageMetrics) uint64
+	}{
+		{"pipelines_items_in_total", "Items received by a stage.", func(s StageMetrics) uint64 { return s.ItemsIn }},
+		{"pipelines_items_out_total", "Items sent on by a stage.", func(s StageMetrics) uint64 { return s.ItemsOut }},
+		{"pipelines_cancellations_total", "Times a stage stopped because its context was cancelled.", func(s StageMetrics) uint64 { return s.Cancellations }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, stage := range stages {
+			fmt.Fprintf(bw, "%s{stage=%q} %d\n", c.name, stage, c.value(snapshot[stage]))
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(StageMetrics) Histogram
+	}{
+		{"pipelines_worker_latency_seconds", "Time taken to process a single item.", func(s StageMetrics) Histogram { return s.WorkerLatency }},
+		{"pipelines_blocked_send_seconds", "Time spent waiting for a downstream reader.", func(s StageMetrics) Histogram { return s.BlockedSend }},
+	}
+	for _, h := range histograms {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for _, stage := range stages {
+			hist := h.value(snapshot[stage])
+			for i, le := range hist.Buckets {
+				fmt.Fprintf(bw, "%s_bucket{stage=%q,le=%q} %d\n", h.name, stage, strconv.FormatFloat(le, 'g', -1, 64), hist.Counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket{stage=%q,le=\"+Inf\"} %d\n", h.name, stage, hist.Count)
+			fmt.Fprintf(bw, "%s_sum{stage=%q} %s\n", h.name, stage, strconv.FormatFloat(hist.Sum, 'g', -1, 64))
+			fmt.Fprintf(bw, "%s_count{stage=%q} %d\n", h.name, stage, hist.Count)
+		}
+	}
+
+	return bw.Flush()
+}
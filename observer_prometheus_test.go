@@ -0,0 +1,44 @@
+package pipelines
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPrometheusHandler(t *testing.T) {
+	t.Run("when m has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewPrometheusHandler(nil) })
+	})
+
+	t.Run("when we request the metrics, we should receive them in the text exposition format", func(t *testing.T) {
+		o := NewMemoryObserver(0.5)
+		o.ItemIn("FanOut")
+		o.ItemOut("FanOut")
+		o.Cancelled("FanIn")
+		o.WorkerLatency("FanOut", time.Second)
+
+		rec := httptest.NewRecorder()
+		NewPrometheusHandler(o).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+		body := rec.Body.String()
+		for _, line := range []string{
+			"# TYPE pipelines_items_in_total counter",
+			`pipelines_items_in_total{stage="FanOut"} 1`,
+			`pipelines_items_in_total{stage="FanIn"} 0`,
+			`pipelines_cancellations_total{stage="FanIn"} 1`,
+			"# TYPE pipelines_worker_latency_seconds histogram",
+			`pipelines_worker_latency_seconds_bucket{stage="FanOut",le="0.5"} 0`,
+			`pipelines_worker_latency_seconds_bucket{stage="FanOut",le="+Inf"} 1`,
+			`pipelines_worker_latency_seconds_sum{stage="FanOut"} 1`,
+			`pipelines_worker_latency_seconds_count{stage="FanOut"} 1`,
+		} {
+			assert.True(t, strings.Contains(body, line+"\n"), "expected body to contain %q", line)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverFromContext(t *testing.T) {
+	t.Run("when no observer has been attached, we should receive one that does nothing", func(t *testing.T) {
+		assert.Equal(t, nopObserver{}, ObserverFromContext(context.Background()))
+	})
+
+	t.Run("when an observer has been attached, we should receive it back", func(t *testing.T) {
+		o := NewMemoryObserver()
+		assert.Same(t, o, ObserverFromContext(WithObserver(context.Background(), o)))
+	})
+}
+
+func TestMemoryObserver(t *testing.T) {
+	var pipeFunc WorkerFunc[string, string] = func(ctx context.Context, item string) string { return item }
+
+	t.Run("when items flow through FanOut and FanIn, we should count them in and out of each stage", func(t *testing.T) {
+		o := NewMemoryObserver()
+		ctx := WithObserver(context.Background(), o)
+		list := []string{"this", "is", "a", "list"}
+
+		outStream := FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, list), 2, pipeFunc))
+		expectStreamLengthToBe(len(list), outStream, t)
+
+		snapshot := o.Snapshot()
+		assert.Equal(t, uint64(len(list)), snapshot["FanOut"].ItemsIn)
+		assert.Equal(t, uint64(len(list)), snapshot["FanOut"].ItemsOut)
+		assert.Equal(t, uint64(len(list)), snapshot["FanOut"].WorkerLatency.Count)
+		assert.Equal(t, uint64(len(list)), snapshot["FanIn"].ItemsIn)
+		assert.Equal(t, uint64(len(list)), snapshot["FanIn"].ItemsOut)
+		assert.Equal(t, uint64(len(list)), snapshot["FanIn"].BlockedSend.Count)
+	})
+
+	t.Run("when items flow through TeeSplitter and Combine, we should count every copy sent out", func(t *testing.T) {
+		o := NewMemoryObserver()
+		ctx := WithObserver(context.Background(), o)
+		list := []string{"hello", "world"}
+
+		o1, o2 := TeeSplitter(ctx, GenerateFromSlice(ctx, list))
+		expectStreamLengthToBe(len(list)*2, Combine(ctx, o1, o2), t)
+
+		snapshot := o.Snapshot()
+		assert.Equal(t, uint64(len(list)), snapshot["TeeSplitter"].ItemsIn)
+		assert.Equal(t, uint64(len(list)*2), snapshot["TeeSplitter"].ItemsOut)
+		assert.Equal(t, uint64(len(list)*2), snapshot["Combine"].ItemsOut)
+	})
+
+	t.Run("when a WorkerThread is cancelled, we should count the cancellation", func(t *testing.T) {
+		o := NewMemoryObserver()
+		ctx, cancel := context.WithCancel(WithObserver(context.Background(), o))
+		outStream := WorkerThread(ctx, make(chan string), pipeFunc)
+		cancel()
+		expectClosedChannel(true, outStream, t)
+
+		assert.Equal(t, uint64(1), o.Snapshot()["WorkerThread"].Cancellations)
+	})
+
+	t.Run("when a HttpReqAsync request completes, we should record its latency", func(t *testing.T) {
+		o := NewMemoryObserver()
+		ctx := WithObserver(context.Background(), o)
+		body, err := json.Marshal(createSampleObject())
+		require.NoError(t, err)
+		client := &http.Client{
+			Transport: newStubRoundTripper(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil),
+		}
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		res := <-HttpReqAsync(ctx, client, req, func(r *http.Response, err error) (int, error) { return r.StatusCode, err })
+		assert.NoError(t, res.Error)
+
+		assert.Eventually(t, func() bool { return o.Snapshot()["HttpReqAsync"].ItemsOut == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, uint64(1), o.Snapshot()["HttpReqAsync"].WorkerLatency.Count)
+	})
+
+	t.Run("when we observe latencies, we should place them into cumulative buckets", func(t *testing.T) {
+		o := NewMemoryObserver(1, 0.1)
+		o.WorkerLatency("stage", time.Millisecond*50)
+		o.WorkerLatency("stage", time.Millisecond*500)
+		o.WorkerLatency("stage", time.Second*5)
+
+		h := o.Snapshot()["stage"].WorkerLatency
+		assert.Equal(t, []float64{0.1, 1}, h.Buckets)
+		assert.Equal(t, []uint64{1, 2}, h.Counts)
+		assert.Equal(t, uint64(3), h.Count)
+		assert.InDelta(t, 5.55, h.Sum, 0.0001)
+	})
+}
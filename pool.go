@@ -0,0 +1,321 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type PoolOptions struct {
+	// MinWorkers is the number of workers the pool starts with and never shrinks below.
+	MinWorkers int
+	// MaxWorkers is the number of workers the pool will never grow beyond.
+	MaxWorkers int
+	// QueueSize is the capacity of the queue that sits between the inStream and the workers.
+	QueueSize int
+	// ScaleInterval is how often the pool reviews its size.
+	ScaleInterval time.Duration
+	// IdleTimeout is how long the pool must go without work before it retires a worker.
+	IdleTimeout time.Duration
+	// TargetLatency, when set, grows the pool whenever the average time taken by the workerFunc exceeds it.
+	TargetLatency time.Duration
+	// WorkerOptions are applied to each of the pool's workers, as they are to those of FanOut, such as ItemTimeout,
+	// Gate or DeadLetterTo.
+	WorkerOptions []WorkerOption
+
+	Clock Clock
+}
+
+type PoolOption func(*PoolOptions)
+
+type PoolStats struct {
+	Workers    int
+	QueueDepth int
+	Processed  uint64
+	// AvgLatency is the average time the workerFunc took over the last ScaleInterval.
+	AvgLatency time.Duration
+}
+
+// Pool is a FanOut/FanIn pair whose number of workers grows and shrinks with the load placed on it.
+type Pool[In, Out any] struct {
+	ops        PoolOptions
+	workerOps  WorkerOptions
+	workerFunc WorkerFunc[In, Out]
+	logger     Logger
+
+	queue     chan In
+	outStream chan Out
+	retire    chan struct{}
+	wg        sync.WaitGroup
+
+	cancel       context.CancelFunc
+	stopIntake   chan struct{}
+	stopOnce     sync.Once
+	shutdownDone chan struct{}
+
+	mu         sync.Mutex
+	workers    int
+	spawned    int
+	processed  uint64
+	latency    time.Duration
+	latencyCnt int
+	avgLatency time.Duration
+	lastWork   time.Time
+}
+
+func NewPool[In, Out any](ctx context.Context, inStream <-chan In, workerFunc WorkerFunc[In, Out], options ...PoolOption) *Pool[In, Out] {
+	if inStream == nil {
+		panic("NewPool: inStream arg has nil value")
+	}
+
+	if workerFunc == nil {
+		panic("NewPool: workerFunc arg has nil value")
+	}
+
+	ops := PoolOptions{
+		MinWorkers:    1,
+		ScaleInterval: time.Millisecond * 100,
+		IdleTimeout:   time.Second * 10,
+		Clock:         RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	if ops.MinWorkers < 1 {
+		ops.MinWorkers = 1
+	}
+	if ops.MaxWorkers < ops.MinWorkers {
+		ops.MaxWorkers = ops.MinWorkers
+	}
+	if ops.QueueSize < 1 {
+		ops.QueueSize = ops.MaxWorkers
+	}
+
+	workerOps := workerOptions(ops.WorkerOptions)
+	if err := checkWorkerOptions[In, Out](workerOps); err != nil {
+		panic(fmt.Sprintf("NewPool: %v", err))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pool[In, Out]{
+		ops:          ops,
+		workerOps:    workerOps,
+		workerFunc:   workerFunc,
+		logger:       LoggerFromContext(ctx).With("stage", "Pool"),
+		queue:        make(chan In, ops.QueueSize),
+		outStream:    make(chan Out),
+		retire:       make(chan struct{}),
+		cancel:       cancel,
+		stopIntake:   make(chan struct{}),
+		shutdownDone: make(chan struct{}),
+		lastWork:     ops.Clock.Now(),
+	}
+
+	p.mu.Lock()
+	for i := 0; i < ops.MinWorkers; i++ {
+		p.spawn(ctx)
+	}
+	p.mu.Unlock()
+
+	intakeDone := make(chan struct{})
+	go func() {
+		defer close(intakeDone)
+		defer close(p.queue)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stopIntake:
+				return
+			case item, ok := <-inStream:
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case p.queue <- item:
+				}
+			}
+		}
+	}()
+
+	supervisorDone := make(chan struct{})
+	go func() {
+		defer close(supervisorDone)
+		ticker := ops.Clock.NewTicker(ops.ScaleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-intakeDone:
+				return
+			case <-ticker.C():
+				p.scale(ctx)
+			}
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		defer close(p.shutdownDone)
+		defer close(p.outStream)
+		<-intakeDone
+		<-supervisorDone
+		p.wg.Wait()
+	}()
+
+	return p
+}
+
+// Out returns the stream of results produced by the pool's workers.
+func (p *Pool[In, Out]) Out() <-chan Out {
+	return p.outStream
+}
+
+// Shutdown stops the pool gracefully: it stops taking items from the inStream, lets the workers finish those already
+// taken, including any still queued, and closes Out once their results have been read. Should ctx be done first, the
+// pool is cancelled, dropping whatever is still in flight, and ctx's error is returned.
+func (p *Pool[In, Out]) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stopIntake) })
+
+	select {
+	case <-p.shutdownDone:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the pool's current size and backlog.
+func (p *Pool[In, Out]) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Workers:    p.workers,
+		QueueDepth: len(p.queue),
+		Processed:  p.processed,
+		AvgLatency: p.avgLatency,
+	}
+}
+
+// spawn must be called with p.mu held.
+func (p *Pool[In, Out]) spawn(ctx context.Context) {
+	p.workers++
+	p.wg.Add(1)
+	go p.work(ctx, p.logger.With("worker", p.spawned))
+	p.spawned++
+}
+
+// work is a worker much like those of FanOut, that can also be retired.
+func (p *Pool[In, Out]) work(ctx context.Context, logger Logger) {
+	defer p.wg.Done()
+	observer := ObserverFromContext(ctx)
+	logger.Debug("worker started")
+	var runner *itemRunner[In, Out]
+	if p.workerOps.ItemTimeout > 0 {
+		runner = &itemRunner[In, Out]{stage: "Pool", workerFunc: p.workerFunc}
+		defer runner.stop()
+	}
+	var shut <-chan struct{}
+	for {
+		if p.workerOps.Gate != nil {
+			if err := p.workerOps.Gate.Wait(ctx); err != nil {
+				observer.Cancelled("Pool")
+				logger.Debug("worker cancelled", "error", err)
+				return
+			}
+			shut = p.workerOps.Gate.Shut()
+		}
+
+		select {
+		case <-ctx.Done():
+			observer.Cancelled("Pool")
+			logger.Debug("worker cancelled", "error", ctx.Err())
+			return
+		case <-p.retire:
+			logger.Debug("worker retired")
+			return
+		case <-shut:
+		case item, ok := <-p.queue:
+			if !ok {
+				logger.Debug("worker finished, queue closed")
+				return
+			}
+			observer.ItemIn("Pool")
+			// the gate may have shut as the item was read, as select picks at random between ready cases
+			if p.workerOps.Gate != nil {
+				if err := p.workerOps.Gate.Wait(ctx); err != nil {
+					observer.Cancelled("Pool")
+					logger.Debug("worker cancelled, item dropped", "error", err)
+					return
+				}
+			}
+
+			start := p.ops.Clock.Now()
+			res, ok := processItem(ctx, "Pool", logger, p.workerOps, runner, item, p.workerFunc)
+			observer.WorkerLatency("Pool", p.record(start))
+			if !ok {
+				continue
+			}
+
+			sendStart := time.Now()
+			select {
+			case p.outStream <- res:
+				observer.BlockedSend("Pool", time.Since(sendStart))
+				observer.ItemOut("Pool")
+			case <-ctx.Done():
+				observer.Cancelled("Pool")
+				logger.Debug("worker cancelled, result dropped", "error", ctx.Err())
+				return
+			}
+		}
+	}
+}
+
+// record counts an item whose work began at start, returning how long it took.
+func (p *Pool[In, Out]) record(start time.Time) time.Duration {
+	now := p.ops.Clock.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed++
+	p.latency += now.Sub(start)
+	p.latencyCnt++
+	p.lastWork = now
+	return now.Sub(start)
+}
+
+func (p *Pool[In, Out]) scale(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.avgLatency = 0
+	if p.latencyCnt > 0 {
+		p.avgLatency = p.latency / time.Duration(p.latencyCnt)
+	}
+	p.latency, p.latencyCnt = 0, 0
+
+	backlog := len(p.queue) > 0
+	slow := p.ops.TargetLatency > 0 && p.avgLatency > p.ops.TargetLatency
+	if backlog || slow {
+		p.lastWork = p.ops.Clock.Now()
+		if p.workers < p.ops.MaxWorkers {
+			p.spawn(ctx)
+		}
+		return
+	}
+
+	if p.workers > p.ops.MinWorkers && p.ops.Clock.Now().Sub(p.lastWork) >= p.ops.IdleTimeout {
+		select {
+		case p.retire <- struct{}{}:
+			p.workers--
+			p.lastWork = p.ops.Clock.Now()
+		default:
+		}
+	}
+}
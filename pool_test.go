@@ -0,0 +1,233 @@
+package pipelines
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPool(t *testing.T) {
+	var double WorkerFunc[int, int] = func(ctx context.Context, in int) int { return in * 2 }
+
+	t.Run("when we pass a nil value instead of a stream, we should receive a panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = NewPool(context.Background(), nil, double)
+		})
+	})
+
+	t.Run("when we pass a nil value instead of a workerFunc, we should receive a panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = NewPool[int, int](context.Background(), GenerateFromSlice(context.Background(), []int{}), nil)
+		})
+	})
+
+	t.Run("when we pass multiple items into the pool, we should receive all of them processed in a closed stream", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewPool(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4, 5}), double, func(po *PoolOptions) {
+			po.MinWorkers = 2
+			po.MaxWorkers = 4
+		})
+
+		got := make([]int, 0)
+		for res := range pool.Out() {
+			got = append(got, res)
+		}
+		sort.Ints(got)
+		assert.Equal(t, []int{2, 4, 6, 8, 10}, got)
+		expectClosedChannel(true, pool.Out(), t)
+	})
+
+	t.Run("when the context is cancelled, we should receive a closed stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		pool := NewPool(ctx, make(chan int), double)
+		cancel()
+		expectStreamLengthToBe(0, pool.Out(), t)
+	})
+
+	t.Run("when there is a backlog of work, the pool should grow up to MaxWorkers", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		clock := newFakeClock()
+		release := make(chan struct{})
+		blocking := func(ctx context.Context, in int) int { <-release; return in }
+
+		pool := NewPool(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4, 5, 6, 7, 8}), blocking, func(po *PoolOptions) {
+			po.MinWorkers = 1
+			po.MaxWorkers = 3
+			po.QueueSize = 4
+			po.ScaleInterval = time.Second
+			po.Clock = clock
+		})
+		assert.Equal(t, 1, pool.Stats().Workers)
+
+		clock.BlockUntil(1)
+		assert.Eventually(t, func() bool { return pool.Stats().QueueDepth == 4 }, time.Second, time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			clock.Advance(time.Second)
+			return pool.Stats().Workers == 3
+		}, time.Second, time.Millisecond*10)
+
+		close(release)
+		expectStreamLengthToBe(8, pool.Out(), t)
+	})
+
+	t.Run("when the pool has been idle for IdleTimeout, it should shrink back to MinWorkers", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		clock := newFakeClock()
+		inStream := make(chan int)
+
+		pool := NewPool(ctx, inStream, double, func(po *PoolOptions) {
+			po.MinWorkers = 1
+			po.MaxWorkers = 3
+			po.ScaleInterval = time.Second
+			po.IdleTimeout = time.Second * 5
+			po.Clock = clock
+		})
+
+		pool.mu.Lock()
+		pool.spawn(ctx)
+		pool.spawn(ctx)
+		pool.mu.Unlock()
+		assert.Equal(t, 3, pool.Stats().Workers)
+
+		clock.BlockUntil(1)
+		for i := 0; i < 4; i++ {
+			clock.Advance(time.Second)
+		}
+		assert.Equal(t, 3, pool.Stats().Workers)
+
+		assert.Eventually(t, func() bool {
+			clock.Advance(time.Second)
+			return pool.Stats().Workers == 1
+		}, time.Second, time.Millisecond*10)
+
+		close(inStream)
+		expectClosedChannel(true, pool.Out(), t)
+	})
+
+	t.Run("when items flow through the pool, we should count them in and out with the ctx Observer", func(t *testing.T) {
+		o := NewMemoryObserver()
+		ctx := WithObserver(context.Background(), o)
+		pool := NewPool(ctx, GenerateFromSlice(ctx, []int{1, 2, 3}), double)
+		expectStreamLengthToBe(3, pool.Out(), t)
+
+		snapshot := o.Snapshot()
+		assert.Equal(t, uint64(3), snapshot["Pool"].ItemsIn)
+		assert.Equal(t, uint64(3), snapshot["Pool"].ItemsOut)
+		assert.Equal(t, uint64(3), snapshot["Pool"].WorkerLatency.Count)
+	})
+
+	t.Run("when an Envelope passes through the pool, the worker should receive the item's context within a span", func(t *testing.T) {
+		type ctxKey struct{}
+		recorder := NewMemorySpanRecorder()
+		ctx := WithSpanRecorder(context.Background(), recorder)
+		itemCtx, finish := StartSpan(context.WithValue(context.Background(), ctxKey{}, "item-value"), "source")
+		defer finish(nil)
+
+		var worker WorkerFunc[Envelope[int], string] = func(ctx context.Context, in Envelope[int]) string {
+			return ctx.Value(ctxKey{}).(string)
+		}
+		pool := NewPool(ctx, GenerateFromSlice(ctx, []Envelope[int]{NewEnvelope(itemCtx, 1)}), worker)
+		expectOrderedResultsList([]string{"item-value"}, pool.Out(), t)
+
+		spans := recorder.Spans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "Pool", spans[0].Name)
+	})
+
+	t.Run("when an item times out, it should be dropped and put in the DeadLetterTo sink", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[int]()
+		release := make(chan struct{})
+		defer close(release)
+		hangsOnOne := func(ctx context.Context, in int) int {
+			if in == 1 {
+				<-release
+			}
+			return in * 2
+		}
+
+		pool := NewPool(ctx, GenerateFromSlice(ctx, []int{1, 2}), hangsOnOne, func(po *PoolOptions) {
+			po.WorkerOptions = []WorkerOption{DeadLetterTo[int](sink), func(ops *WorkerOptions) {
+				ops.ItemTimeout = time.Millisecond * 10
+				ops.OnItemTimeout = func(*ItemTimeoutError) {}
+			}}
+		})
+		expectOrderedResultsList([]int{4}, pool.Out(), t)
+
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 1)
+		assert.Equal(t, 1, letters[0].Item)
+		var timeoutErr *ItemTimeoutError
+		assert.ErrorAs(t, letters[0].Err, &timeoutErr)
+	})
+
+	t.Run("when the WorkerOptions can't be used with the workerFunc, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = NewPool(context.Background(), make(chan int), double, func(po *PoolOptions) {
+				po.WorkerOptions = []WorkerOption{func(ops *WorkerOptions) { ops.SendItemTimeouts = true }}
+			})
+		})
+	})
+}
+
+func TestPoolShutdown(t *testing.T) {
+	t.Run("when the pool is shut down, the items it has taken should still be processed before Out closes", func(t *testing.T) {
+		ctx := context.Background()
+		inStream := make(chan int)
+		release := make(chan struct{})
+		blocking := func(ctx context.Context, in int) int { <-release; return in * 2 }
+
+		pool := NewPool(ctx, inStream, blocking, func(po *PoolOptions) {
+			po.QueueSize = 4
+		})
+		// one item held by the worker and two queued
+		for i := 1; i <= 3; i++ {
+			inStream <- i
+		}
+
+		shutdownErr := make(chan error, 1)
+		go func() { shutdownErr <- pool.Shutdown(ctx) }()
+		close(release)
+
+		got := make([]int, 0)
+		for res := range pool.Out() {
+			got = append(got, res)
+		}
+		assert.Equal(t, []int{2, 4, 6}, got)
+		assert.NoError(t, <-shutdownErr)
+
+		select {
+		case inStream <- 4:
+			t.Error("expected the pool to have stopped reading the inStream")
+		default:
+		}
+	})
+
+	t.Run("when the items can't be finished before ctx is done, the pool should be cancelled", func(t *testing.T) {
+		inStream := make(chan int)
+		hanging := func(ctx context.Context, in int) int { <-ctx.Done(); return in }
+		pool := NewPool(context.Background(), inStream, hanging)
+		inStream <- 1
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+		for range pool.Out() {
+		}
+		expectClosedChannel(true, pool.Out(), t)
+	})
+
+	t.Run("when Shutdown is called more than once, it should not panic", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewPool(ctx, GenerateFromSlice(ctx, []int{}), func(ctx context.Context, in int) int { return in })
+		assert.NoError(t, pool.Shutdown(ctx))
+		assert.NoError(t, pool.Shutdown(ctx))
+	})
+}
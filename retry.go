@@ -0,0 +1,119 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy says how often, and how soon, WithRetry tries an item again. Fields left at zero take their defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the most times an item is tried, including the first. It defaults to 3, and a negative value
+	// leaves the attempts limited only by MaxElapsedTime and the ctx.
+	MaxAttempts int
+	// InitialInterval is the wait before the first retry, which defaults to 100ms. Each wait after is Multiplier times
+	// longer than the last, which defaults to 2, up to MaxInterval, which defaults to 10s.
+	InitialInterval time.Duration
+	Multiplier      float64
+	MaxInterval     time.Duration
+	// Jitter varies each wait at random by up to this fraction of it either way, so that workers that failed together
+	// don't all retry together. Zero means no jitter.
+	Jitter float64
+	// MaxElapsedTime is how long after the first attempt began a retry may still start. Zero means no limit.
+	MaxElapsedTime time.Duration
+	// IsRetryable reports whether an error is worth retrying. By default every error is.
+	IsRetryable func(err error) bool
+
+	Clock Clock
+}
+
+// RetryError is returned by WithRetry when it gives up on an item, wrapping the last error.
+type RetryError struct {
+	Err      error
+	attempts int
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %v", e.attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Attempts is the number of times the item was tried.
+func (e *RetryError) Attempts() int {
+	return e.attempts
+}
+
+// WithRetry wraps workerFunc so that an item it fails on is tried again, with an exponentially growing wait between
+// attempts, until it succeeds, an error isn't retryable or the policy's limits are reached. The wait is cut short if
+// ctx is cancelled. Once it gives up, a *RetryError holding the last error is returned.
+func WithRetry[In, Out any](workerFunc ErrWorkerFunc[In, Out], policy RetryPolicy) ErrWorkerFunc[In, Out] {
+	if workerFunc == nil {
+		panic("WithRetry: workerFunc arg has nil value")
+	}
+
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = time.Millisecond * 100
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = time.Second * 10
+	}
+	if policy.IsRetryable == nil {
+		policy.IsRetryable = func(error) bool { return true }
+	}
+	if policy.Clock == nil {
+		policy.Clock = RealClock()
+	}
+
+	return func(ctx context.Context, in In) (Out, error) {
+		start := policy.Clock.Now()
+		interval := policy.InitialInterval
+
+		for attempt := 1; ; attempt++ {
+			res, err := workerFunc(ctx, in)
+			if err == nil {
+				return res, nil
+			}
+
+			if !policy.IsRetryable(err) || (policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts) {
+				return res, &RetryError{Err: err, attempts: attempt}
+			}
+
+			wait := jittered(interval, policy.Jitter)
+			if policy.MaxElapsedTime > 0 && policy.Clock.Now().Add(wait).Sub(start) > policy.MaxElapsedTime {
+				return res, &RetryError{Err: err, attempts: attempt}
+			}
+
+			LoggerFromContext(ctx).Debug("retrying item", "attempt", attempt, "wait", wait, "error", err)
+			timer := policy.Clock.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return res, &RetryError{Err: errors.Join(err, ctx.Err()), attempts: attempt}
+			case <-timer.C():
+			}
+
+			interval = time.Duration(float64(interval) * policy.Multiplier)
+			if interval > policy.MaxInterval {
+				interval = policy.MaxInterval
+			}
+		}
+	}
+}
+
+func jittered(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,141 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRetry(t *testing.T) {
+	errTransient := errors.New("connection reset")
+
+	// failTimes returns a worker that fails n times before succeeding, counting its calls
+	failTimes := func(n int32, calls *int32) ErrWorkerFunc[string, string] {
+		return func(ctx context.Context, in string) (string, error) {
+			if atomic.AddInt32(calls, 1) <= n {
+				return "", errTransient
+			}
+			return "uploaded " + in, nil
+		}
+	}
+
+	run := func(workerFunc ErrWorkerFunc[string, string]) <-chan Result[string] {
+		res := make(chan Result[string], 1)
+		go func() { res <- workerFunc.Results()(context.Background(), "file.txt") }()
+		return res
+	}
+
+	t.Run("when workerFunc has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { WithRetry[string, string](nil, RetryPolicy{}) })
+	})
+
+	t.Run("when the worker fails then succeeds, we should back off exponentially and return its result", func(t *testing.T) {
+		clock := newFakeClock()
+		var calls int32
+		res := run(WithRetry(failTimes(2, &calls), RetryPolicy{Clock: clock}))
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond * 99)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		clock.Advance(time.Millisecond)
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond * 199)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		clock.Advance(time.Millisecond)
+
+		r := <-res
+		require.NoError(t, r.Error)
+		assert.Equal(t, "uploaded file.txt", r.Res)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("when MaxAttempts is reached, we should receive a RetryError holding the last error", func(t *testing.T) {
+		var calls int32
+		_, err := WithRetry(failTimes(10, &calls), RetryPolicy{MaxAttempts: 3, InitialInterval: time.Microsecond})(context.Background(), "file.txt")
+
+		var retryErr *RetryError
+		require.ErrorAs(t, err, &retryErr)
+		assert.Equal(t, 3, retryErr.Attempts())
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, "failed after 3 attempts: connection reset", err.Error())
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("when an error isn't retryable, we should give up straight away", func(t *testing.T) {
+		var calls int32
+		_, err := WithRetry(failTimes(10, &calls), RetryPolicy{
+			IsRetryable: func(err error) bool { return !errors.Is(err, errTransient) },
+		})(context.Background(), "file.txt")
+
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("when the next wait would pass MaxElapsedTime, we should give up", func(t *testing.T) {
+		clock := newFakeClock()
+		var calls int32
+		res := run(WithRetry(failTimes(10, &calls), RetryPolicy{
+			MaxAttempts:    -1,
+			MaxElapsedTime: time.Millisecond * 250,
+			Clock:          clock,
+		}))
+
+		// waits of 100ms then 200ms: the second would end 300ms after the start
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond * 100)
+
+		r := <-res
+		var retryErr *RetryError
+		require.ErrorAs(t, r.Error, &retryErr)
+		assert.Equal(t, 2, retryErr.Attempts())
+	})
+
+	t.Run("when there is jitter, each wait should stay within its bounds", func(t *testing.T) {
+		clock := newFakeClock()
+		var calls int32
+		res := run(WithRetry(failTimes(1, &calls), RetryPolicy{Jitter: 0.5, Clock: clock}))
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond * 49)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		clock.Advance(time.Millisecond * 101)
+
+		assert.NoError(t, (<-res).Error)
+	})
+
+	t.Run("when ctx is cancelled during a wait, we should stop waiting", func(t *testing.T) {
+		clock := newFakeClock()
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int32
+		res := make(chan error, 1)
+		go func() {
+			_, err := WithRetry(failTimes(10, &calls), RetryPolicy{Clock: clock})(ctx, "file.txt")
+			res <- err
+		}()
+
+		clock.BlockUntil(1)
+		cancel()
+		err := <-res
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, errTransient)
+	})
+
+	t.Run("when used in FanOut with WithDeadLetter, dead letters should record the attempts", func(t *testing.T) {
+		ctx := context.Background()
+		sink := NewMemoryDeadLetterSink[string]()
+		var calls int32
+		workerFunc := WithDeadLetter(sink, WithRetry(failTimes(10, &calls), RetryPolicy{InitialInterval: time.Microsecond}))
+
+		for range FanIn(ctx, FanOut(ctx, GenerateFromSlice(ctx, []string{"a.txt"}), 1, workerFunc.Results())) {
+		}
+		letters, _ := sink.DeadLetters()
+		require.Len(t, letters, 1)
+		assert.Equal(t, 3, letters[0].Attempts)
+	})
+}
@@ -0,0 +1,95 @@
+package pipelines
+
+import (
+	"context"
+	"time"
+)
+
+// Partition sends each item of inStream to exactly one of two streams: matched if pred holds for it, and unmatched if
+// not. Both streams need reading, as an item waits for the stream it is bound for.
+func Partition[T any](ctx context.Context, inStream <-chan T, pred func(T) bool) (matched, unmatched <-chan T) {
+	if inStream == nil {
+		panic("Partition: inStream arg has nil value")
+	}
+
+	if pred == nil {
+		panic("Partition: pred arg has nil value")
+	}
+
+	matchedStream := make(chan T)
+	unmatchedStream := make(chan T)
+
+	go func() {
+		defer close(matchedStream)
+		defer close(unmatchedStream)
+
+		route(ctx, "Partition", inStream, func(item T) chan<- T {
+			if pred(item) {
+				return matchedStream
+			}
+			return unmatchedStream
+		})
+	}()
+
+	return matchedStream, unmatchedStream
+}
+
+// Route sends each item of inStream to exactly one stream: the one for the key that keyFunc gives it, or the unknown
+// stream if that key isn't one of keys. Every stream needs reading, as an item waits for the stream it is bound for.
+func Route[T any, K comparable](ctx context.Context, inStream <-chan T, keyFunc func(T) K, keys ...K) (routes map[K]<-chan T, unknown <-chan T) {
+	if inStream == nil {
+		panic("Route: inStream arg has nil value")
+	}
+
+	if keyFunc == nil {
+		panic("Route: keyFunc arg has nil value")
+	}
+
+	streams := make(map[K]chan T, len(keys))
+	routes = make(map[K]<-chan T, len(keys))
+	for _, key := range keys {
+		if _, ok := streams[key]; ok {
+			continue
+		}
+		streams[key] = make(chan T)
+		routes[key] = streams[key]
+	}
+	unknownStream := make(chan T)
+
+	go func() {
+		defer func() {
+			for _, stream := range streams {
+				close(stream)
+			}
+			close(unknownStream)
+		}()
+
+		route(ctx, "Route", inStream, func(item T) chan<- T {
+			if stream, ok := streams[keyFunc(item)]; ok {
+				return stream
+			}
+			return unknownStream
+		})
+	}()
+
+	return routes, unknownStream
+}
+
+// route sends each item of inStream on the stream that pick chooses for it, reporting to the ctx Observer under the
+// given stage name.
+func route[T any](ctx context.Context, stage string, inStream <-chan T, pick func(T) chan<- T) {
+	observer := ObserverFromContext(ctx)
+
+	for item := range OrDone(ctx, inStream) {
+		observer.ItemIn(stage)
+		sendStart := time.Now()
+		select {
+		case <-ctx.Done():
+			observer.Cancelled(stage)
+			return
+		case pick(item) <- item:
+			observer.BlockedSend(stage, time.Since(sendStart))
+			observer.ItemOut(stage)
+		}
+	}
+}
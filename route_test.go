@@ -0,0 +1,96 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drainAll reads every stream until closed, returning what was read from each in order.
+func drainAll[T any](streams ...<-chan T) [][]T {
+	res := make([][]T, len(streams))
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		wg.Add(1)
+		go func(i int, stream <-chan T) {
+			defer wg.Done()
+			for item := range stream {
+				res[i] = append(res[i], item)
+			}
+		}(i, stream)
+	}
+	wg.Wait()
+	return res
+}
+
+func TestPartition(t *testing.T) {
+	isEven := func(i int) bool { return i%2 == 0 }
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { Partition[int](context.Background(), nil, isEven) })
+		assert.Panics(t, func() { Partition(context.Background(), make(chan int), nil) })
+	})
+
+	t.Run("when items are partitioned, each should go to exactly one stream", func(t *testing.T) {
+		ctx := context.Background()
+		matched, unmatched := Partition(ctx, GenerateFromSlice(ctx, []int{1, 2, 3, 4, 5}), isEven)
+
+		res := drainAll(matched, unmatched)
+		assert.Equal(t, []int{2, 4}, res[0])
+		assert.Equal(t, []int{1, 3, 5}, res[1])
+	})
+
+	t.Run("when error results are partitioned, they can be sent to a separate sink", func(t *testing.T) {
+		ctx := context.Background()
+		results := GenerateFromSlice(ctx, []Result[int]{{Res: 1}, {Error: errors.New("boom")}, {Res: 3}})
+		failed, succeeded := Partition(ctx, results, func(r Result[int]) bool { return r.Error != nil })
+
+		res := drainAll(failed, succeeded)
+		assert.Len(t, res[0], 1)
+		assert.Len(t, res[1], 2)
+	})
+
+	t.Run("when ctx is cancelled, both streams should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		matched, unmatched := Partition(ctx, make(chan int), isEven)
+		cancel()
+		expectClosedChannel(true, matched, t)
+		expectClosedChannel(true, unmatched, t)
+	})
+}
+
+func TestRoute(t *testing.T) {
+	type event struct {
+		Kind string
+		Id   int
+	}
+	kindOf := func(e event) string { return e.Kind }
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { Route[event, string](context.Background(), nil, kindOf) })
+		assert.Panics(t, func() { Route[event, string](context.Background(), make(chan event), nil) })
+	})
+
+	t.Run("when items are routed, each should go to the stream for its key, or the unknown stream", func(t *testing.T) {
+		ctx := context.Background()
+		events := GenerateFromSlice(ctx, []event{{"fader", 1}, {"mute", 2}, {"fader", 3}, {"scene", 4}})
+		routes, unknown := Route(ctx, events, kindOf, "fader", "mute")
+
+		assert.Len(t, routes, 2)
+		res := drainAll(routes["fader"], routes["mute"], unknown)
+		assert.Equal(t, []event{{"fader", 1}, {"fader", 3}}, res[0])
+		assert.Equal(t, []event{{"mute", 2}}, res[1])
+		assert.Equal(t, []event{{"scene", 4}}, res[2])
+	})
+
+	t.Run("when ctx is cancelled, every stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		routes, unknown := Route(ctx, make(chan event), kindOf, "fader")
+		cancel()
+		expectClosedChannel(true, routes["fader"], t)
+		expectClosedChannel(true, unknown, t)
+	})
+}
@@ -0,0 +1,266 @@
+package pipelines
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Encoder writes items out in some format, such as for ToWriter and ToFile.
+type Encoder[T any] interface {
+	Encode(item T) error
+	// Flush writes out anything the encoder has buffered.
+	Flush() error
+}
+
+// NewLineEncoder writes each item on its own line, formatted as by fmt.Println.
+func NewLineEncoder[T any](w io.Writer) Encoder[T] {
+	return &lineEncoder[T]{w: bufio.NewWriter(w)}
+}
+
+type lineEncoder[T any] struct {
+	w *bufio.Writer
+}
+
+func (e *lineEncoder[T]) Encode(item T) error {
+	_, err := fmt.Fprintln(e.w, item)
+	return err
+}
+
+func (e *lineEncoder[T]) Flush() error {
+	return e.w.Flush()
+}
+
+// NewJSONLinesEncoder writes each item as a JSON object on its own line.
+func NewJSONLinesEncoder[T any](w io.Writer) Encoder[T] {
+	bw := bufio.NewWriter(w)
+	return &jsonLinesEncoder[T]{w: bw, enc: json.NewEncoder(bw)}
+}
+
+type jsonLinesEncoder[T any] struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonLinesEncoder[T]) Encode(item T) error {
+	return e.enc.Encode(item)
+}
+
+func (e *jsonLinesEncoder[T]) Flush() error {
+	return e.w.Flush()
+}
+
+// NewCSVEncoder writes each record as a line of CSV.
+func NewCSVEncoder(w io.Writer) Encoder[[]string] {
+	return csvEncoder{w: csv.NewWriter(w)}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e csvEncoder) Encode(record []string) error {
+	return e.w.Write(record)
+}
+
+func (e csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ToWriter encodes every item read from in to w, using the encoder made by newEncoder, until in is closed. It returns
+// the first error from encoding, or ctx's error if ctx is cancelled first. Either way, what has been encoded is flushed.
+func ToWriter[T any](ctx context.Context, in <-chan T, w io.Writer, newEncoder func(io.Writer) Encoder[T]) error {
+	if in == nil {
+		panic("ToWriter: in arg has nil value")
+	}
+
+	if w == nil {
+		panic("ToWriter: w arg has nil value")
+	}
+
+	if newEncoder == nil {
+		panic("ToWriter: newEncoder arg has nil value")
+	}
+
+	enc := newEncoder(w)
+	err := encodeAll(ctx, in, enc)
+	if flushErr := enc.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func encodeAll[T any](ctx context.Context, in <-chan T, enc Encoder[T]) error {
+	observer := ObserverFromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			observer.Cancelled("ToWriter")
+			return ctx.Err()
+		case item, ok := <-in:
+			if !ok {
+				return nil
+			}
+			observer.ItemIn("ToWriter")
+			if err := enc.Encode(item); err != nil {
+				return fmt.Errorf("failed to encode item: %w", err)
+			}
+		}
+	}
+}
+
+// ToFile is ToWriter for the file at path. Items are written to a temporary file beside it, which only replaces the
+// file at path once in is closed and every item has been written, so the file is never left half written.
+func ToFile[T any](ctx context.Context, in <-chan T, path string, newEncoder func(io.Writer) Encoder[T]) (err error) {
+	if in == nil {
+		panic("ToFile: in arg has nil value")
+	}
+
+	if newEncoder == nil {
+		panic("ToFile: newEncoder arg has nil value")
+	}
+
+	tmp, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := ToWriter(ctx, in, tmp, newEncoder); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// createTemp creates a temporary file beside path, to be renamed over it once written. Unlike with os.CreateTemp,
+// which always uses 0600, the file is given the mode of the file at path, or, if there isn't one yet, 0644 less the
+// umask, as though it had been created at path directly.
+func createTemp(path string) (*os.File, error) {
+	perm, replacing := os.FileMode(0o644), false
+	if info, err := os.Stat(path); err == nil {
+		perm, replacing = info.Mode().Perm(), true
+	}
+
+	for {
+		name := filepath.Join(filepath.Dir(path), filepath.Base(path)+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		tmp, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the umask applies on creation, but mustn't narrow the mode of the file being replaced
+		if replacing {
+			if err := tmp.Chmod(perm); err != nil {
+				tmp.Close()
+				os.Remove(name)
+				return nil, err
+			}
+		}
+		return tmp, nil
+	}
+}
+
+// ForEach calls fn with every item read from in, with up to concurrency calls running at once, until in is closed.
+// Should fn return an error, the ctx given to the calls still running is cancelled, no more items are read and the
+// error is returned once they have finished.
+func ForEach[T any](ctx context.Context, in <-chan T, concurrency int, fn func(ctx context.Context, item T) error) error {
+	if in == nil {
+		panic("ForEach: in arg has nil value")
+	}
+
+	if fn == nil {
+		panic("ForEach: fn arg has nil value")
+	}
+
+	if concurrency < 1 {
+		panic("ForEach: concurrency must be at least 1")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	slots := make(chan struct{}, concurrency)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break loop
+		case slots <- struct{}{}:
+		}
+
+		select {
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break loop
+		case item, ok := <-in:
+			if !ok {
+				break loop
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				if err := fn(ctx, item); err != nil {
+					fail(err)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// Drain reads and discards every item from in until it is closed, returning ctx's error if ctx is cancelled first.
+func Drain[T any](ctx context.Context, in <-chan T) error {
+	if in == nil {
+		panic("Drain: in arg has nil value")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-in:
+			if !ok {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestToWriter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { _ = ToWriter[int](ctx, nil, &bytes.Buffer{}, NewLineEncoder[int]) })
+		assert.Panics(t, func() { _ = ToWriter(ctx, make(chan int), nil, NewLineEncoder[int]) })
+		assert.Panics(t, func() { _ = ToWriter(ctx, make(chan int), &bytes.Buffer{}, nil) })
+	})
+
+	t.Run("when items are written as lines, each should be on its own line", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, ToWriter(ctx, GenerateFromSlice(ctx, []int{1, 2, 3}), &buf, NewLineEncoder[int]))
+		assert.Equal(t, "1\n2\n3\n", buf.String())
+	})
+
+	t.Run("when items are written as JSON Lines, each should be an object on its own line", func(t *testing.T) {
+		var buf bytes.Buffer
+		items := GenerateFromSlice(ctx, []sampleObject{{Id: 1, Name: "Jane", Age: 30}})
+		require.NoError(t, ToWriter(ctx, items, &buf, NewJSONLinesEncoder[sampleObject]))
+		assert.JSONEq(t, `{"_id":1,"name":"Jane","age":30}`, buf.String())
+		assert.Equal(t, byte('\n'), buf.Bytes()[buf.Len()-1])
+	})
+
+	t.Run("when records are written as CSV, they should be quoted as needed", func(t *testing.T) {
+		var buf bytes.Buffer
+		records := GenerateFromSlice(ctx, [][]string{{"sku", "name"}, {"A1", "Mixer, 32ch"}})
+		require.NoError(t, ToWriter(ctx, records, &buf, NewCSVEncoder))
+		assert.Equal(t, "sku,name\nA1,\"Mixer, 32ch\"\n", buf.String())
+	})
+
+	t.Run("when the writer fails, we should receive its error", func(t *testing.T) {
+		err := ToWriter(ctx, GenerateFromSlice(ctx, []int{1}), failingWriter{}, NewLineEncoder[int])
+		assert.ErrorContains(t, err, "disk full")
+	})
+
+	t.Run("when ctx is cancelled, we should receive its error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, ToWriter(ctx, make(chan int), &bytes.Buffer{}, NewLineEncoder[int]), context.Canceled)
+	})
+}
+
+func TestToFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("when every item is written, the file should be replaced with them", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.txt")
+		require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+		require.NoError(t, ToFile(ctx, GenerateFromSlice(ctx, []string{"a", "b"}), path, NewLineEncoder[string]))
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, "a\nb\n", string(data))
+		entries, _ := os.ReadDir(filepath.Dir(path))
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("when the file is created, it should have the usual mode rather than that of a temporary file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.txt")
+		require.NoError(t, ToFile(ctx, GenerateFromSlice(ctx, []string{"a"}), path, NewLineEncoder[string]))
+
+		probe := filepath.Join(t.TempDir(), "probe")
+		require.NoError(t, os.WriteFile(probe, nil, 0o644))
+		want, _ := os.Stat(probe)
+		got, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want.Mode().Perm(), got.Mode().Perm())
+	})
+
+	t.Run("when the file is replaced, it should keep its mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.txt")
+		require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+		require.NoError(t, os.Chmod(path, 0o640))
+
+		require.NoError(t, ToFile(ctx, GenerateFromSlice(ctx, []string{"a"}), path, NewLineEncoder[string]))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("when ctx is cancelled, the file should be left as it was", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.txt")
+		require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		inStream := make(chan string)
+		go func() {
+			inStream <- "a"
+			cancel()
+		}()
+		assert.ErrorIs(t, ToFile(ctx, inStream, path, NewLineEncoder[string]), context.Canceled)
+
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, "old\n", string(data))
+		entries, _ := os.ReadDir(filepath.Dir(path))
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("when the directory doesn't exist, we should receive an error", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "out.txt")
+		assert.Error(t, ToFile(ctx, GenerateFromSlice(ctx, []string{"a"}), path, NewLineEncoder[string]))
+	})
+}
+
+func TestForEach(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("when any of the required args have a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { _ = ForEach[int](ctx, nil, 1, func(context.Context, int) error { return nil }) })
+		assert.Panics(t, func() { _ = ForEach[int](ctx, make(chan int), 1, nil) })
+		assert.Panics(t, func() { _ = ForEach(ctx, make(chan int), 0, func(context.Context, int) error { return nil }) })
+	})
+
+	t.Run("when fn is called, no more than concurrency calls should run at once", func(t *testing.T) {
+		var running, most, sum int32
+		// the first calls wait for each other, so that two are known to have run at once
+		bothRunning := make(chan struct{})
+		var once sync.Once
+		err := ForEach(ctx, GenerateFromSlice(ctx, []int32{1, 2, 3, 4, 5, 6}), 2, func(ctx context.Context, in int32) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			if n == 2 {
+				once.Do(func() { close(bothRunning) })
+			}
+			select {
+			case <-bothRunning:
+			case <-time.After(time.Second * 5):
+			}
+			atomic.AddInt32(&sum, in)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int32(21), sum)
+		assert.Equal(t, int32(2), most)
+	})
+
+	t.Run("when fn fails, we should receive its error and the other calls should be cancelled", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		inStream := make(chan int)
+		go func() {
+			defer close(inStream)
+			for i := 0; ; i++ {
+				select {
+				case inStream <- i:
+				case <-time.After(time.Second):
+					return
+				}
+			}
+		}()
+
+		var cancelled int32
+		err := ForEach(ctx, inStream, 2, func(ctx context.Context, in int) error {
+			if in == 1 {
+				return errBoom
+			}
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, errBoom)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled))
+	})
+}
+
+func TestDrain(t *testing.T) {
+	t.Run("when in has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { _ = Drain[int](context.Background(), nil) })
+	})
+
+	t.Run("when in is closed, every item should have been read", func(t *testing.T) {
+		ctx := context.Background()
+		inStream := GenerateFromSliceBuffered(ctx, []int{1, 2, 3})
+		require.NoError(t, Drain(ctx, inStream))
+		assert.Len(t, inStream, 0)
+	})
+
+	t.Run("when ctx is cancelled, we should receive its error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, Drain(ctx, make(chan int)), context.Canceled)
+	})
+}
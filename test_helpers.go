@@ -2,7 +2,10 @@ package pipelines
 
 import (
 	"reflect"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 )
 
 func expectClosedChannel[T any](expect bool, stream <-chan T, t testing.TB) {
@@ -55,3 +58,123 @@ func getIndexOf[T comparable](want T, list []T) int {
 	}
 	return -1
 }
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return c.addTimer(d, 0)
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	return fakeTicker{c.addTimer(d, d)}
+}
+
+func (c *fakeClock) addTimer(d, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1), when: c.now.Add(d), period: period, active: true}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker that falls due on the way.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target := c.now.Add(d)
+	for {
+		due := make([]*fakeTimer, 0)
+		for _, t := range c.timers {
+			if t.active && !t.when.After(target) {
+				due = append(due, t)
+			}
+		}
+		if len(due) == 0 {
+			break
+		}
+		sort.Slice(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+		t := due[0]
+		c.now = t.when
+		select {
+		case t.ch <- c.now:
+		default:
+		}
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			t.active = false
+		}
+	}
+	c.now = target
+	c.cond.Broadcast()
+}
+
+// BlockUntil waits until at least n timers or tickers are active on the clock.
+func (c *fakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.activeTimers() < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *fakeClock) activeTimers() (count int) {
+	for _, t := range c.timers {
+		if t.active {
+			count++
+		}
+	}
+	return
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	ch     chan time.Time
+	when   time.Time
+	period time.Duration
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.active
+	t.active = false
+	t.clock.cond.Broadcast()
+	return wasActive
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.active
+	t.active = true
+	t.when = t.clock.now.Add(d)
+	t.clock.cond.Broadcast()
+	return wasActive
+}
+
+type fakeTicker struct{ *fakeTimer }
+
+func (t fakeTicker) Stop() { t.fakeTimer.Stop() }
@@ -0,0 +1,269 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type TimingOptions struct {
+	Clock Clock
+}
+
+type TimingOption func(*TimingOptions)
+
+type ThrottleOptions struct {
+	// Leading sends the first item of each interval as soon as it arrives.
+	Leading bool
+	// Trailing sends the last item to arrive during an interval once the interval is over.
+	Trailing bool
+
+	Clock Clock
+}
+
+type ThrottleOption func(*ThrottleOptions)
+
+var ErrStreamTimeout = errors.New("no item received within timeout")
+
+func timingOptions(options []TimingOption) TimingOptions {
+	ops := TimingOptions{
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	return ops
+}
+
+// Debounce sends an item only once d has passed without another arriving, so that a burst of items becomes the last
+// of them. When inStream closes, an item still waiting out its quiet period is sent straight away.
+func Debounce[T any](ctx context.Context, inStream <-chan T, d time.Duration, options ...TimingOption) <-chan T {
+	if inStream == nil {
+		panic("Debounce: inStream arg has nil value")
+	}
+
+	ops := timingOptions(options)
+	outStream := make(chan T)
+
+	go func() {
+		defer close(outStream)
+		timer := ops.Clock.NewTimer(d)
+		timer.Stop()
+		defer timer.Stop()
+
+		var pending T
+		var hasPending bool
+		for {
+			var quiet <-chan time.Time
+			if hasPending {
+				quiet = timer.C()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-inStream:
+				if !ok {
+					if hasPending {
+						sendOrDone(ctx, outStream, pending)
+					}
+					return
+				}
+				pending, hasPending = item, true
+				resetTimer(timer, d)
+			case <-quiet:
+				hasPending = false
+				if !sendOrDone(ctx, outStream, pending) {
+					return
+				}
+			}
+		}
+	}()
+
+	return outStream
+}
+
+// Throttle sends no more than one item every d. By default both the first item of an interval and the last one to
+// arrive during it are sent, the last at the end of the interval, and the items in between are dropped.
+func Throttle[T any](ctx context.Context, inStream <-chan T, d time.Duration, options ...ThrottleOption) <-chan T {
+	if inStream == nil {
+		panic("Throttle: inStream arg has nil value")
+	}
+
+	ops := ThrottleOptions{
+		Leading:  true,
+		Trailing: true,
+		Clock:    RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	if !ops.Leading && !ops.Trailing {
+		ops.Leading = true
+	}
+
+	outStream := make(chan T)
+
+	go func() {
+		defer close(outStream)
+		timer := ops.Clock.NewTimer(d)
+		timer.Stop()
+		defer timer.Stop()
+
+		var pending T
+		var hasPending, inInterval bool
+		for {
+			var intervalOver <-chan time.Time
+			if inInterval {
+				intervalOver = timer.C()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-inStream:
+				if !ok {
+					if hasPending {
+						sendOrDone(ctx, outStream, pending)
+					}
+					return
+				}
+				if !inInterval {
+					inInterval = true
+					resetTimer(timer, d)
+					if ops.Leading {
+						if !sendOrDone(ctx, outStream, item) {
+							return
+						}
+						continue
+					}
+				}
+				if ops.Trailing {
+					pending, hasPending = item, true
+				}
+			case <-intervalOver:
+				if !hasPending {
+					inInterval = false
+					continue
+				}
+				// sending the trailing item starts a new interval, so that items are never closer together than d
+				hasPending = false
+				resetTimer(timer, d)
+				if !sendOrDone(ctx, outStream, pending) {
+					return
+				}
+			}
+		}
+	}()
+
+	return outStream
+}
+
+// Sample sends the latest item to have arrived every d, sending nothing for an interval in which no item arrived.
+// When inStream closes, an item that has not yet been sent is sent straight away.
+func Sample[T any](ctx context.Context, inStream <-chan T, d time.Duration, options ...TimingOption) <-chan T {
+	if inStream == nil {
+		panic("Sample: inStream arg has nil value")
+	}
+
+	ops := timingOptions(options)
+	outStream := make(chan T)
+
+	go func() {
+		defer close(outStream)
+		ticker := ops.Clock.NewTicker(d)
+		defer ticker.Stop()
+
+		var latest T
+		var hasLatest bool
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-inStream:
+				if !ok {
+					if hasLatest {
+						sendOrDone(ctx, outStream, latest)
+					}
+					return
+				}
+				latest, hasLatest = item, true
+			case <-ticker.C():
+				if !hasLatest {
+					continue
+				}
+				hasLatest = false
+				if !sendOrDone(ctx, outStream, latest) {
+					return
+				}
+			}
+		}
+	}()
+
+	return outStream
+}
+
+// StreamTimeout passes items on from inStream, failing with ErrStreamTimeout on the error stream if d passes without
+// one arriving. The time spent waiting for the consumer to take an item doesn't count. Both streams are closed once
+// inStream closes, the timeout is hit or ctx is cancelled.
+func StreamTimeout[T any](ctx context.Context, inStream <-chan T, d time.Duration, options ...TimingOption) (<-chan T, <-chan error) {
+	if inStream == nil {
+		panic("StreamTimeout: inStream arg has nil value")
+	}
+
+	ops := timingOptions(options)
+	outStream := make(chan T)
+	errStream := make(chan error, 1)
+
+	go func() {
+		defer close(outStream)
+		defer close(errStream)
+		timer := ops.Clock.NewTimer(d)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-inStream:
+				if !ok {
+					return
+				}
+				if !sendOrDone(ctx, outStream, item) {
+					return
+				}
+				resetTimer(timer, d)
+			case <-timer.C():
+				errStream <- ErrStreamTimeout
+				return
+			}
+		}
+	}()
+
+	return outStream, errStream
+}
+
+// sendOrDone sends item on outStream, reporting false if ctx is cancelled first.
+func sendOrDone[T any](ctx context.Context, outStream chan<- T, item T) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case outStream <- item:
+		return true
+	}
+}
+
+// resetTimer restarts t for d, first draining a fire that hasn't been received, as the timers' Reset requires.
+func resetTimer(t Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+	t.Reset(d)
+}
@@ -0,0 +1,193 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiveAfterAdvancing moves clock on by d until an item arrives on stream, as the stage under test may not have
+// reset its timer by the time we first advance.
+func receiveAfterAdvancing[T any](t *testing.T, clock *fakeClock, d time.Duration, stream <-chan T) T {
+	var item T
+	require.Eventually(t, func() bool {
+		clock.Advance(d)
+		select {
+		case item = <-stream:
+			return true
+		case <-time.After(time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	return item
+}
+
+func expectNothingFor[T any](t *testing.T, stream <-chan T) {
+	select {
+	case item, ok := <-stream:
+		t.Errorf("expected nothing on the stream, received %v (open %v)", item, ok)
+	case <-time.After(time.Millisecond * 20):
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	withClock := func(clock Clock) TimingOption { return func(o *TimingOptions) { o.Clock = clock } }
+
+	t.Run("when inStream has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { Debounce[int](context.Background(), nil, time.Second) })
+	})
+
+	t.Run("when items arrive in a burst, only the last should be sent once things go quiet", func(t *testing.T) {
+		clock := newFakeClock()
+		in := make(chan int)
+		out := Debounce(context.Background(), in, time.Millisecond*100, withClock(clock))
+
+		in <- 1
+		in <- 2
+		in <- 3
+		clock.Advance(time.Millisecond * 50)
+		expectNothingFor(t, out)
+
+		assert.Equal(t, 3, receiveAfterAdvancing(t, clock, time.Millisecond*100, out))
+		close(in)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when inStream closes during the quiet period, the pending item should be sent", func(t *testing.T) {
+		in := make(chan int)
+		out := Debounce(context.Background(), in, time.Hour, withClock(newFakeClock()))
+
+		in <- 1
+		close(in)
+		assert.Equal(t, 1, <-out)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when ctx is cancelled, the stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Debounce(ctx, make(chan int), time.Hour, withClock(newFakeClock()))
+		cancel()
+		expectClosedChannel(true, out, t)
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	t.Run("when inStream has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { Throttle[int](context.Background(), nil, time.Second) })
+	})
+
+	t.Run("when leading and trailing, the first and last items of each interval should be sent", func(t *testing.T) {
+		clock := newFakeClock()
+		in := make(chan int)
+		out := Throttle(context.Background(), in, time.Millisecond*100, func(o *ThrottleOptions) { o.Clock = clock })
+
+		in <- 1
+		assert.Equal(t, 1, <-out)
+		in <- 2
+		in <- 3
+		expectNothingFor(t, out)
+		assert.Equal(t, 3, receiveAfterAdvancing(t, clock, time.Millisecond*100, out))
+
+		// the trailing item starts an interval of its own
+		in <- 4
+		expectNothingFor(t, out)
+		assert.Equal(t, 4, receiveAfterAdvancing(t, clock, time.Millisecond*100, out))
+
+		close(in)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when leading only, items arriving during the interval should be dropped", func(t *testing.T) {
+		in := make(chan int)
+		out := Throttle(context.Background(), in, time.Hour, func(o *ThrottleOptions) {
+			o.Trailing = false
+			o.Clock = newFakeClock()
+		})
+
+		in <- 1
+		assert.Equal(t, 1, <-out)
+		in <- 2
+		in <- 3
+		close(in)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when trailing only, the last item should be sent at the end of the interval", func(t *testing.T) {
+		clock := newFakeClock()
+		in := make(chan int)
+		out := Throttle(context.Background(), in, time.Millisecond*100, func(o *ThrottleOptions) {
+			o.Leading = false
+			o.Clock = clock
+		})
+
+		in <- 1
+		in <- 2
+		expectNothingFor(t, out)
+		assert.Equal(t, 2, receiveAfterAdvancing(t, clock, time.Millisecond*100, out))
+	})
+}
+
+func TestSample(t *testing.T) {
+	t.Run("when inStream has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { Sample[int](context.Background(), nil, time.Second) })
+	})
+
+	t.Run("when the ticker ticks, the latest item should be sent", func(t *testing.T) {
+		clock := newFakeClock()
+		in := make(chan int)
+		out := Sample(context.Background(), in, time.Millisecond*100, func(o *TimingOptions) { o.Clock = clock })
+		clock.BlockUntil(1)
+
+		in <- 1
+		in <- 2
+		clock.Advance(time.Millisecond * 100)
+		assert.Equal(t, 2, <-out)
+
+		// nothing new has arrived, so the next tick should send nothing
+		clock.Advance(time.Millisecond * 100)
+		expectNothingFor(t, out)
+
+		in <- 3
+		close(in)
+		assert.Equal(t, 3, <-out)
+		expectClosedChannel(true, out, t)
+	})
+}
+
+func TestStreamTimeout(t *testing.T) {
+	t.Run("when inStream has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { StreamTimeout[int](context.Background(), nil, time.Second) })
+	})
+
+	t.Run("when no item arrives within d, we should receive ErrStreamTimeout", func(t *testing.T) {
+		clock := newFakeClock()
+		out, errStream := StreamTimeout(context.Background(), make(chan int), time.Second, func(o *TimingOptions) { o.Clock = clock })
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		assert.ErrorIs(t, <-errStream, ErrStreamTimeout)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when items keep arriving, the timeout should keep being put off", func(t *testing.T) {
+		clock := newFakeClock()
+		in := make(chan int)
+		out, errStream := StreamTimeout(context.Background(), in, time.Second, func(o *TimingOptions) { o.Clock = clock })
+		clock.BlockUntil(1)
+
+		for i := 0; i < 3; i++ {
+			clock.Advance(time.Millisecond * 600)
+			in <- i
+			assert.Equal(t, i, <-out)
+			// give the stage a moment to reset its timer
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		close(in)
+		expectClosedChannel(true, out, t)
+		assert.NoError(t, <-errStream)
+	})
+}
@@ -0,0 +1,211 @@
+package pipelines
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span within a trace, in the same terms as the W3C Trace Context specification.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent returns sc formatted as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent reads a SpanContext from a W3C traceparent header value.
+func ParseTraceparent(s string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, fmt.Errorf("%w: %v", ErrInvalidTraceparent, err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, fmt.Errorf("%w: %v", ErrInvalidTraceparent, err)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("%w: %v", ErrInvalidTraceparent, err)
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	return sc, nil
+}
+
+type spanContextCtxKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextCtxKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextCtxKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Span is a single timed operation within a trace.
+type Span struct {
+	Name string
+	SpanContext
+	Parent SpanID
+	Start  time.Time
+	End    time.Time
+	Err    error
+}
+
+// SpanRecorder receives every span once it has ended.
+type SpanRecorder interface {
+	Record(Span)
+}
+
+type spanRecorderCtxKey struct{}
+
+// WithSpanRecorder returns a copy of ctx that causes every span started from it to be recorded by r.
+func WithSpanRecorder(ctx context.Context, r SpanRecorder) context.Context {
+	return context.WithValue(ctx, spanRecorderCtxKey{}, r)
+}
+
+// StartSpan starts a span as a child of the span held by ctx, or as the root of a new trace when ctx holds none.
+// The returned context carries the new span and the returned func ends it, recording the given error against it and
+// handing it to the ctx SpanRecorder. Only the first call to the func has any effect.
+func StartSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	s := Span{Name: name, Start: time.Now()}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.TraceID = parent.TraceID
+		s.Parent = parent.SpanID
+		s.Sampled = parent.Sampled
+	} else {
+		_, _ = rand.Read(s.TraceID[:])
+		s.Sampled = true
+	}
+	_, _ = rand.Read(s.SpanID[:])
+	recorder, _ := ctx.Value(spanRecorderCtxKey{}).(SpanRecorder)
+
+	var once sync.Once
+	return ContextWithSpanContext(ctx, s.SpanContext), func(err error) {
+		once.Do(func() {
+			s.End = time.Now()
+			s.Err = err
+			if recorder != nil {
+				recorder.Record(s)
+			}
+		})
+	}
+}
+
+// MemorySpanRecorder keeps every span it is given in memory.
+type MemorySpanRecorder struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func NewMemorySpanRecorder() *MemorySpanRecorder {
+	return &MemorySpanRecorder{}
+}
+
+func (r *MemorySpanRecorder) Record(s Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the recorded spans in the order they ended.
+func (r *MemorySpanRecorder) Spans() []Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Span(nil), r.spans...)
+}
+
+// Envelope carries an item through a pipeline together with a context of its own, so that trace ids, deadlines and
+// values belonging to that item are not lost in favour of the pipeline wide context.
+type Envelope[T any] struct {
+	Ctx  context.Context
+	Item T
+}
+
+func NewEnvelope[T any](ctx context.Context, item T) Envelope[T] {
+	return Envelope[T]{Ctx: ctx, Item: item}
+}
+
+func (e Envelope[T]) Context() context.Context {
+	if e.Ctx == nil {
+		return context.Background()
+	}
+	return e.Ctx
+}
+
+type itemContextCarrier interface {
+	Context() context.Context
+}
+
+// itemContext takes its deadline, cancellation and values from the item's context, but is also cancelled along
+// with the pipeline and falls back to the pipeline's values.
+type itemContext struct {
+	context.Context
+	pipeline context.Context
+}
+
+func (c itemContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.pipeline.Value(key)
+}
+
+// withItemContext returns the context that a worker should be handed for item, along with a func that releases it.
+func withItemContext[T any](pipeline context.Context, item T) (context.Context, func()) {
+	carrier, ok := interface{}(item).(itemContextCarrier)
+	if !ok {
+		return pipeline, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(carrier.Context())
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-pipeline.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	return itemContext{Context: ctx, pipeline: pipeline}, func() {
+		close(stop)
+		cancel()
+	}
+}
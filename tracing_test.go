@@ -0,0 +1,172 @@
+package pipelines
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Run("when we parse a valid traceparent, we should receive the same value back when formatting it", func(t *testing.T) {
+		header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		sc, err := ParseTraceparent(header)
+		require.NoError(t, err)
+		assert.True(t, sc.Sampled)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+		assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+		assert.Equal(t, header, sc.Traceparent())
+	})
+
+	t.Run("when we parse an invalid traceparent, we should receive an ErrInvalidTraceparent", func(t *testing.T) {
+		for _, header := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-zzf067aa0ba902b7-01",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		} {
+			_, err := ParseTraceparent(header)
+			assert.ErrorIs(t, err, ErrInvalidTraceparent, header)
+		}
+	})
+}
+
+func TestStartSpan(t *testing.T) {
+	t.Run("when ctx holds no span, we should start a new trace", func(t *testing.T) {
+		ctx, finish := StartSpan(context.Background(), "root")
+		finish(nil)
+		sc, ok := SpanContextFromContext(ctx)
+		assert.True(t, ok)
+		assert.True(t, sc.IsValid())
+	})
+
+	t.Run("when ctx holds a span, we should record a child of it in the same trace", func(t *testing.T) {
+		recorder := NewMemorySpanRecorder()
+		ctx, finishParent := StartSpan(WithSpanRecorder(context.Background(), recorder), "parent")
+		_, finishChild := StartSpan(ctx, "child")
+		finishChild(context.Canceled)
+		finishChild(nil)
+		finishParent(nil)
+
+		spans := recorder.Spans()
+		require.Len(t, spans, 2)
+		assert.Equal(t, "child", spans[0].Name)
+		assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+		assert.Equal(t, spans[1].SpanID, spans[0].Parent)
+		assert.ErrorIs(t, spans[0].Err, context.Canceled)
+	})
+}
+
+func TestEnvelope(t *testing.T) {
+	type ctxKey struct{}
+
+	t.Run("when an Envelope passes through a WorkerThread, the worker should receive the item's context", func(t *testing.T) {
+		recorder := NewMemorySpanRecorder()
+		pipelineCtx := WithSpanRecorder(context.Background(), recorder)
+		itemCtx, finish := StartSpan(context.WithValue(context.Background(), ctxKey{}, "item-value"), "source")
+		defer finish(nil)
+		parent, _ := SpanContextFromContext(itemCtx)
+
+		var worker WorkerFunc[Envelope[int], string] = func(ctx context.Context, in Envelope[int]) string {
+			sc, _ := SpanContextFromContext(ctx)
+			assert.Equal(t, parent.TraceID, sc.TraceID)
+			assert.NotEqual(t, parent.SpanID, sc.SpanID)
+			return ctx.Value(ctxKey{}).(string)
+		}
+
+		outStream := WorkerThread(pipelineCtx, GenerateFromSlice(pipelineCtx, []Envelope[int]{NewEnvelope(itemCtx, 1)}), worker)
+		expectOrderedResultsList([]string{"item-value"}, outStream, t)
+
+		spans := recorder.Spans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "WorkerThread", spans[0].Name)
+		assert.Equal(t, parent.SpanID, spans[0].Parent)
+	})
+
+	t.Run("when the pipeline is cancelled, the item's context should be cancelled too", func(t *testing.T) {
+		pipelineCtx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		var worker WorkerFunc[Envelope[int], error] = func(ctx context.Context, in Envelope[int]) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		outStream := WorkerThread(pipelineCtx, GenerateFromSlice(context.Background(), []Envelope[int]{NewEnvelope(context.Background(), 1)}), worker)
+		<-started
+		cancel()
+		expectClosedChannel(true, outStream, t)
+	})
+
+	t.Run("when the item's deadline passes, the worker's context should be done", func(t *testing.T) {
+		itemCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		var worker WorkerFunc[Envelope[int], error] = func(ctx context.Context, in Envelope[int]) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		ctx := context.Background()
+		outStream := WorkerThread(ctx, GenerateFromSlice(ctx, []Envelope[int]{NewEnvelope(itemCtx, 1)}), worker)
+		expectOrderedResultsList([]error{context.DeadlineExceeded}, outStream, t)
+	})
+}
+
+func TestHttpReqAsyncTracing(t *testing.T) {
+	t.Run("when ctx is part of a trace, we should send a traceparent header for a child span", func(t *testing.T) {
+		recorder := NewMemorySpanRecorder()
+		ctx, finish := StartSpan(WithSpanRecorder(context.Background(), recorder), "parent")
+		defer finish(nil)
+
+		var gotHeader string
+		client := HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			gotHeader = req.Header.Get("traceparent")
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		res := <-HttpReqAsync(ctx, client, req, func(r *http.Response, err error) (int, error) { return r.StatusCode, err })
+		require.NoError(t, res.Error)
+
+		sc, err := ParseTraceparent(gotHeader)
+		require.NoError(t, err)
+		spans := recorder.Spans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, spans[0].SpanContext, sc)
+		assert.Empty(t, req.Header.Get("traceparent"))
+	})
+
+	t.Run("when ctx is not part of a trace, we should not send a traceparent header", func(t *testing.T) {
+		var gotHeader string
+		client := HttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			gotHeader = req.Header.Get("traceparent")
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		<-HttpReqAsync(context.Background(), client, req, func(r *http.Response, err error) (int, error) { return r.StatusCode, err })
+		assert.Empty(t, gotHeader)
+	})
+
+	t.Run("when the client returns neither a response nor an error, the span should record ErrNilResponse", func(t *testing.T) {
+		recorder := NewMemorySpanRecorder()
+		ctx, finish := StartSpan(WithSpanRecorder(context.Background(), recorder), "parent")
+		defer finish(nil)
+
+		client := HttpClientFunc(func(req *http.Request) (*http.Response, error) { return nil, nil })
+		req, err := http.NewRequest(http.MethodGet, "https://my-api/objects/v1/1", nil)
+		require.NoError(t, err)
+
+		res := <-HttpReqAsync(ctx, client, req, JSONHandler[sampleObject]())
+		assert.ErrorIs(t, res.Error, ErrNilResponse)
+		spans := recorder.Spans()
+		require.Len(t, spans, 1)
+		assert.ErrorIs(t, spans[0].Err, ErrNilResponse)
+	})
+}
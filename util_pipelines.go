@@ -3,6 +3,7 @@ package pipelines
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 func OrDone[R any](ctx context.Context, inStream <-chan R) <-chan R {
@@ -45,23 +46,30 @@ func TeeSplitter[R any](ctx context.Context, inStream <-chan R) (_, _ <-chan R)
 		panic("TeeSplitter: the provided inStream argument has nil value")
 	}
 
+	observer := ObserverFromContext(ctx)
+
 	go func() {
 		defer close(outStream1)
 		defer close(outStream2)
 
 		for item := range OrDone(ctx, inStream) {
 			var outStream1, outStream2 = outStream1, outStream2
+			observer.ItemIn("TeeSplitter")
+			sendStart := time.Now()
 
 			for i := 0; i < 2; i++ {
 				select {
 				case <-ctx.Done():
+					observer.Cancelled("TeeSplitter")
 					return
 				case outStream1 <- item:
 					outStream1 = nil
 				case outStream2 <- item:
 					outStream2 = nil
 				}
+				observer.ItemOut("TeeSplitter")
 			}
+			observer.BlockedSend("TeeSplitter", time.Since(sendStart))
 		}
 	}()
 
@@ -72,6 +80,7 @@ func TeeSplitter[R any](ctx context.Context, inStream <-chan R) (_, _ <-chan R)
 func Combine[T any](ctx context.Context, channels ...<-chan T) <-chan T {
 	outStream := make(chan T)
 	wg := sync.WaitGroup{}
+	observer := ObserverFromContext(ctx)
 
 	worker := func(inStream <-chan T) {
 		defer wg.Done()
@@ -80,10 +89,15 @@ func Combine[T any](ctx context.Context, channels ...<-chan T) <-chan T {
 		}
 
 		for val := range OrDone(ctx, inStream) {
+			observer.ItemIn("Combine")
+			sendStart := time.Now()
 			select {
 			case <-ctx.Done():
+				observer.Cancelled("Combine")
 				return
 			case outStream <- val:
+				observer.BlockedSend("Combine", time.Since(sendStart))
+				observer.ItemOut("Combine")
 			}
 		}
 	}
@@ -0,0 +1,147 @@
+package pipelines
+
+import (
+	"context"
+	"sync"
+)
+
+// ValveState is whether a Valve is letting items through.
+type ValveState int
+
+const (
+	ValveOpen ValveState = iota
+	ValvePaused
+)
+
+func (s ValveState) String() string {
+	switch s {
+	case ValveOpen:
+		return "Open"
+	case ValvePaused:
+		return "Paused"
+	default:
+		return "Unknown"
+	}
+}
+
+// Gate holds up workers while it is shut. See WorkerOptions.
+type Gate interface {
+	// Wait blocks while the gate is shut, or until ctx is done, when it returns ctx's error.
+	Wait(ctx context.Context) error
+	// Shut returns a channel that is closed once the gate is shut, so that a worker waiting for an item can stop.
+	Shut() <-chan struct{}
+}
+
+// Valve stops items being read from a stream while it is paused, without tearing the pipeline down. It can be put in
+// a pipeline with Stage, or given to FanOut or WorkerThread as their Gate. A Valve starts open.
+type Valve[T any] struct {
+	mu      sync.Mutex
+	state   ValveState
+	paused  chan struct{}
+	resumed chan struct{}
+}
+
+func NewValve[T any]() *Valve[T] {
+	return &Valve[T]{state: ValveOpen, paused: make(chan struct{})}
+}
+
+// Pause stops the valve letting items through. Items already sent on are unaffected, while one read just as the valve
+// is paused is held until it is resumed.
+func (v *Valve[T]) Pause() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.state == ValvePaused {
+		return
+	}
+	v.state = ValvePaused
+	v.resumed = make(chan struct{})
+	close(v.paused)
+}
+
+// Resume lets items through the valve again.
+func (v *Valve[T]) Resume() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.state == ValveOpen {
+		return
+	}
+	v.state = ValveOpen
+	v.paused = make(chan struct{})
+	close(v.resumed)
+}
+
+func (v *Valve[T]) State() ValveState {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.state
+}
+
+// Wait blocks while the valve is paused, or until ctx is done, when it returns ctx's error.
+func (v *Valve[T]) Wait(ctx context.Context) error {
+	for {
+		v.mu.Lock()
+		if v.state == ValveOpen {
+			v.mu.Unlock()
+			return ctx.Err()
+		}
+		resumed := v.resumed
+		v.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resumed:
+		}
+	}
+}
+
+// Shut returns a channel that is closed once the valve is paused.
+func (v *Valve[T]) Shut() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.paused
+}
+
+// Stage returns a stream of the items read from in, which stops reading from in whenever the valve is paused.
+func (v *Valve[T]) Stage(ctx context.Context, in <-chan T) <-chan T {
+	if in == nil {
+		panic("Stage: in arg has nil value")
+	}
+
+	outStream := make(chan T)
+	observer := ObserverFromContext(ctx)
+
+	go func() {
+		defer close(outStream)
+		for {
+			if err := v.Wait(ctx); err != nil {
+				observer.Cancelled("Valve")
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				observer.Cancelled("Valve")
+				return
+			case <-v.Shut():
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				observer.ItemIn("Valve")
+				// the valve may have been paused as the item was read, as select picks at random between ready cases
+				if err := v.Wait(ctx); err != nil {
+					observer.Cancelled("Valve")
+					return
+				}
+				if !sendOrDone(ctx, outStream, item) {
+					observer.Cancelled("Valve")
+					return
+				}
+				observer.ItemOut("Valve")
+			}
+		}
+	}()
+
+	return outStream
+}
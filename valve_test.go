@@ -0,0 +1,159 @@
+package pipelines
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signallingGate tells a test each time a worker waits on, or looks for, the gate it wraps, so that the test knows
+// where the worker has got to.
+type signallingGate struct {
+	Gate
+	waits chan struct{}
+	shuts chan struct{}
+}
+
+func newSignallingGate(gate Gate) *signallingGate {
+	return &signallingGate{Gate: gate, waits: make(chan struct{}, 100), shuts: make(chan struct{}, 100)}
+}
+
+func (g *signallingGate) Wait(ctx context.Context) error {
+	g.waits <- struct{}{}
+	return g.Gate.Wait(ctx)
+}
+
+func (g *signallingGate) Shut() <-chan struct{} {
+	g.shuts <- struct{}{}
+	return g.Gate.Shut()
+}
+
+// pausingGate pauses its valve the first time a worker looks for it shutting, so that the worker finds the gate shut
+// and an item waiting at once.
+type pausingGate struct {
+	*Valve[int]
+	once   sync.Once
+	paused chan struct{}
+}
+
+func (g *pausingGate) Shut() <-chan struct{} {
+	g.once.Do(func() {
+		g.Pause()
+		close(g.paused)
+	})
+	return g.Valve.Shut()
+}
+
+func TestValve(t *testing.T) {
+	t.Run("when in has a nil value, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { NewValve[int]().Stage(context.Background(), nil) })
+	})
+
+	t.Run("when the valve is paused and resumed, its state should follow", func(t *testing.T) {
+		valve := NewValve[int]()
+		assert.Equal(t, ValveOpen, valve.State())
+		valve.Pause()
+		valve.Pause()
+		assert.Equal(t, ValvePaused, valve.State())
+		assert.Equal(t, "Paused", valve.State().String())
+		valve.Resume()
+		valve.Resume()
+		assert.Equal(t, ValveOpen, valve.State())
+	})
+
+	t.Run("when the valve is paused, no items should pass until it is resumed", func(t *testing.T) {
+		ctx := context.Background()
+		valve := NewValve[int]()
+		inStream := make(chan int, 3)
+		outStream := valve.Stage(ctx, inStream)
+
+		inStream <- 1
+		assert.Equal(t, 1, <-outStream)
+
+		valve.Pause()
+		inStream <- 2
+		inStream <- 3
+		expectNothingFor(t, outStream)
+		assert.Len(t, inStream, 2)
+
+		valve.Resume()
+		assert.Equal(t, 2, <-outStream)
+		assert.Equal(t, 3, <-outStream)
+		close(inStream)
+		expectClosedChannel(true, outStream, t)
+	})
+
+	t.Run("when ctx is cancelled while paused, Wait should return and the stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		valve := NewValve[int]()
+		valve.Pause()
+		outStream := valve.Stage(ctx, make(chan int))
+		cancel()
+		assert.ErrorIs(t, valve.Wait(ctx), context.Canceled)
+		expectClosedChannel(true, outStream, t)
+	})
+
+	t.Run("when the valve is the Gate of FanOut, no worker should take items while it is paused", func(t *testing.T) {
+		ctx := context.Background()
+		valve := NewValve[int]()
+		valve.Pause()
+		inStream := make(chan int, 4)
+		for i := 1; i <= 4; i++ {
+			inStream <- i
+		}
+		close(inStream)
+
+		gate := newSignallingGate(valve)
+		outStream := FanIn(ctx, FanOut(ctx, inStream, 3, func(ctx context.Context, in int) int { return in * 10 }, func(ops *WorkerOptions) {
+			ops.Gate = gate
+		}))
+		// FanIn reads the workers in turn, so only the first has been started, and it is now held up by the valve
+		<-gate.waits
+		assert.Len(t, inStream, 4)
+
+		valve.Resume()
+		expectStreamLengthToBe(4, outStream, t)
+	})
+
+	t.Run("when the valve is paused while a worker waits for an item, the worker should not take the next one", func(t *testing.T) {
+		ctx := context.Background()
+		valve := NewValve[int]()
+		inStream := make(chan int, 1)
+		gate := newSignallingGate(valve)
+		outStream := WorkerThread(ctx, inStream, func(ctx context.Context, in int) int { return in }, func(ops *WorkerOptions) {
+			ops.Gate = gate
+		})
+
+		// once the worker has looked for the gate shutting, it is waiting on the inStream
+		<-gate.shuts
+		valve.Pause()
+		inStream <- 1
+		expectNothingFor(t, outStream)
+
+		valve.Resume()
+		assert.Equal(t, 1, <-outStream)
+	})
+
+	t.Run("when the gate shuts just as an item arrives, the worker should hold the item until it opens", func(t *testing.T) {
+		ctx := context.Background()
+		// the worker picks between the two at random, so try enough times for it to have taken the item
+		for i := 0; i < 10; i++ {
+			gate := &pausingGate{Valve: NewValve[int](), paused: make(chan struct{})}
+			inStream := make(chan int, 1)
+			inStream <- 1
+			outStream := WorkerThread(ctx, inStream, func(ctx context.Context, in int) int { return in }, func(ops *WorkerOptions) {
+				ops.Gate = gate
+			})
+
+			<-gate.paused
+			expectNothingFor(t, outStream)
+			if t.Failed() {
+				return
+			}
+			gate.Resume()
+			assert.Equal(t, 1, <-outStream)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package pipelines
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Window is the aggregated result of the items whose times fall within [Start, End).
+type Window[A any] struct {
+	Start  time.Time
+	End    time.Time
+	Result A
+	Count  int
+	// Late is set when the window is sent because of an item that arrived after the watermark passed its end, which
+	// usually means it is being sent again with that item added.
+	Late bool
+}
+
+type WindowOptions[T any] struct {
+	// EventTime returns the time at which an item happened. When nil, items are windowed by the time they arrive.
+	EventTime func(item T) time.Time
+	// OutOfOrderness is how far behind the latest event time seen the watermark is held, so that items arriving a
+	// little out of order still make it into their windows before they are sent. A window is sent once the watermark
+	// passes its end.
+	OutOfOrderness time.Duration
+	// AllowedLateness is how long after the watermark passes a window's end that items still count towards it. Each
+	// such item sends the window again, marked Late.
+	AllowedLateness time.Duration
+	// OnLate is called with items that arrive too late for any of their windows, which are otherwise dropped.
+	OnLate func(item T)
+
+	Clock Clock
+}
+
+type WindowOption[T any] func(*WindowOptions[T])
+
+// TumblingWindow groups the items of inStream into back to back windows of the given size, folding each window's items
+// together with aggregate, starting from the zero value of A. Windows are aligned to multiples of size.
+func TumblingWindow[T, A any](ctx context.Context, inStream <-chan T, size time.Duration, aggregate func(acc A, item T) A, options ...WindowOption[T]) <-chan Window[A] {
+	if inStream == nil {
+		panic("TumblingWindow: inStream arg has nil value")
+	}
+
+	if aggregate == nil {
+		panic("TumblingWindow: aggregate arg has nil value")
+	}
+
+	if size <= 0 {
+		panic("TumblingWindow: size must be positive")
+	}
+
+	return windowStream(ctx, inStream, size, size, aggregate, options)
+}
+
+// SlidingWindow groups the items of inStream into overlapping windows of the given size, starting every slide, so that
+// each item counts towards size/slide windows. Each window's items are folded together with aggregate, starting from
+// the zero value of A. When slide is larger than size the windows hop, leaving gaps between them whose items are
+// dropped without being passed to OnLate.
+func SlidingWindow[T, A any](ctx context.Context, inStream <-chan T, size, slide time.Duration, aggregate func(acc A, item T) A, options ...WindowOption[T]) <-chan Window[A] {
+	if inStream == nil {
+		panic("SlidingWindow: inStream arg has nil value")
+	}
+
+	if aggregate == nil {
+		panic("SlidingWindow: aggregate arg has nil value")
+	}
+
+	if size <= 0 || slide <= 0 {
+		panic("SlidingWindow: size and slide must be positive")
+	}
+
+	return windowStream(ctx, inStream, size, slide, aggregate, options)
+}
+
+type windowState[A any] struct {
+	window Window[A]
+	sent   bool
+}
+
+func windowStream[T, A any](ctx context.Context, inStream <-chan T, size, slide time.Duration, aggregate func(A, T) A, options []WindowOption[T]) <-chan Window[A] {
+	ops := WindowOptions[T]{
+		Clock: RealClock(),
+	}
+
+	for _, optFunc := range options {
+		optFunc(&ops)
+	}
+
+	outStream := make(chan Window[A])
+
+	go func() {
+		defer close(outStream)
+
+		windows := make(map[time.Time]*windowState[A])
+		var watermark time.Time
+
+		// windows by processing time are sent when the clock reaches their end, rather than when an item shows it has
+		var timer Timer
+		var timerSet bool
+		if ops.EventTime == nil {
+			timer = ops.Clock.NewTimer(size)
+			timer.Stop()
+			defer timer.Stop()
+		}
+		schedule := func() {
+			var next time.Time
+			for _, w := range windows {
+				if !w.sent && (next.IsZero() || w.window.End.Before(next)) {
+					next = w.window.End
+				}
+			}
+			timerSet = !next.IsZero()
+			if timerSet {
+				resetTimer(timer, next.Sub(ops.Clock.Now()))
+			}
+		}
+
+		// due sends, earliest first, the windows the watermark has passed, and forgets those that can no
+		// longer take late items. When flush is set, every window is sent.
+		due := func(flush bool) bool {
+			var ready []*windowState[A]
+			for start, w := range windows {
+				if !w.sent && (flush || !watermark.Before(w.window.End)) {
+					ready = append(ready, w)
+				}
+				if flush || !watermark.Before(w.window.End.Add(ops.AllowedLateness)) {
+					delete(windows, start)
+				}
+			}
+			sort.Slice(ready, func(i, j int) bool { return ready[i].window.Start.Before(ready[j].window.Start) })
+			for _, w := range ready {
+				w.sent = true
+				if !sendOrDone(ctx, outStream, w.window) {
+					return false
+				}
+			}
+			return true
+		}
+
+		add := func(item T, at time.Time) bool {
+			// an item in the gap between hopping windows, when slide is larger than size, is in none of them without being
+			// late for any
+			accepted, tooLate := false, false
+			var late []Window[A]
+			for start := at.Truncate(slide); start.Add(size).After(at); start = start.Add(-slide) {
+				end := start.Add(size)
+				if !watermark.Before(end.Add(ops.AllowedLateness)) {
+					tooLate = true
+					continue
+				}
+				accepted = true
+
+				w, ok := windows[start]
+				if !ok {
+					w = &windowState[A]{window: Window[A]{Start: start, End: end}}
+					windows[start] = w
+				}
+				w.window.Result = aggregate(w.window.Result, item)
+				w.window.Count++
+
+				// a window the watermark has already passed goes straight out again
+				if w.sent || !watermark.Before(end) {
+					w.sent = true
+					updated := w.window
+					updated.Late = true
+					late = append(late, updated)
+				}
+			}
+
+			if !accepted && tooLate && ops.OnLate != nil {
+				ops.OnLate(item)
+			}
+			sort.Slice(late, func(i, j int) bool { return late[i].Start.Before(late[j].Start) })
+			for _, w := range late {
+				if !sendOrDone(ctx, outStream, w) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			var timerC <-chan time.Time
+			if timerSet {
+				timerC = timer.C()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-inStream:
+				if !ok {
+					due(true)
+					return
+				}
+
+				var at time.Time
+				if ops.EventTime != nil {
+					at = ops.EventTime(item)
+				} else {
+					at = ops.Clock.Now()
+				}
+				if !add(item, at) {
+					return
+				}
+
+				if ops.EventTime != nil {
+					if mark := at.Add(-ops.OutOfOrderness); mark.After(watermark) {
+						watermark = mark
+					}
+				} else {
+					watermark = at
+				}
+				if !due(false) {
+					return
+				}
+				if ops.EventTime == nil {
+					schedule()
+				}
+			case <-timerC:
+				timerSet = false
+				watermark = ops.Clock.Now()
+				if !due(false) {
+					return
+				}
+				schedule()
+			}
+		}
+	}()
+
+	return outStream
+}
@@ -0,0 +1,156 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type meterReading struct {
+	At    time.Time
+	Level int
+}
+
+func TestTumblingWindow(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(secs int, level int) meterReading {
+		return meterReading{At: t0.Add(time.Duration(secs) * time.Second), Level: level}
+	}
+	sum := func(acc int, r meterReading) int { return acc + r.Level }
+	byEventTime := func(o *WindowOptions[meterReading]) {
+		o.EventTime = func(r meterReading) time.Time { return r.At }
+	}
+	collect := func(windows <-chan Window[int]) (res []Window[int]) {
+		for w := range windows {
+			res = append(res, w)
+		}
+		return res
+	}
+	window := func(startSecs, endSecs, result, count int, late bool) Window[int] {
+		return Window[int]{
+			Start:  t0.Add(time.Duration(startSecs) * time.Second),
+			End:    t0.Add(time.Duration(endSecs) * time.Second),
+			Result: result,
+			Count:  count,
+			Late:   late,
+		}
+	}
+
+	t.Run("when any of the required args are missing, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { TumblingWindow[meterReading, int](context.Background(), nil, time.Second, sum) })
+		assert.Panics(t, func() {
+			TumblingWindow[meterReading, int](context.Background(), make(chan meterReading), time.Second, nil)
+		})
+		assert.Panics(t, func() { TumblingWindow(context.Background(), make(chan meterReading), 0, sum) })
+	})
+
+	t.Run("when the watermark passes a window's end, the window should be sent", func(t *testing.T) {
+		in := make(chan meterReading)
+		out := TumblingWindow(context.Background(), in, time.Second*10, sum, byEventTime)
+
+		in <- at(1, 1)
+		in <- at(3, 2)
+		in <- at(12, 5)
+		assert.Equal(t, window(0, 10, 3, 2, false), <-out)
+
+		close(in)
+		assert.Equal(t, []Window[int]{window(10, 20, 5, 1, false)}, collect(out))
+	})
+
+	t.Run("when items are out of order within OutOfOrderness, they should make it into their window", func(t *testing.T) {
+		out := TumblingWindow(context.Background(), GenerateFromSlice(context.Background(), []meterReading{
+			at(1, 1), at(12, 5), at(3, 2), at(16, 1),
+		}), time.Second*10, sum, byEventTime, func(o *WindowOptions[meterReading]) {
+			o.OutOfOrderness = time.Second * 5
+		})
+
+		assert.Equal(t, []Window[int]{window(0, 10, 3, 2, false), window(10, 20, 6, 2, false)}, collect(out))
+	})
+
+	t.Run("when items arrive within AllowedLateness, the window should be sent again marked late", func(t *testing.T) {
+		var dropped []meterReading
+		out := TumblingWindow(context.Background(), GenerateFromSlice(context.Background(), []meterReading{
+			at(1, 1), at(12, 5), at(4, 2), at(16, 1), at(2, 7),
+		}), time.Second*10, sum, byEventTime, func(o *WindowOptions[meterReading]) {
+			o.AllowedLateness = time.Second * 5
+			o.OnLate = func(r meterReading) { dropped = append(dropped, r) }
+		})
+
+		assert.Equal(t, []Window[int]{
+			window(0, 10, 1, 1, false),
+			window(0, 10, 3, 2, true),
+			window(10, 20, 6, 2, false),
+		}, collect(out))
+		assert.Equal(t, []meterReading{at(2, 7)}, dropped)
+	})
+
+	t.Run("when windowing by processing time, windows should be sent as the clock passes their end", func(t *testing.T) {
+		clock := newFakeClock()
+		in := make(chan meterReading)
+		out := TumblingWindow(context.Background(), in, time.Second, sum, func(o *WindowOptions[meterReading]) {
+			o.Clock = clock
+		})
+
+		in <- meterReading{Level: 1}
+		in <- meterReading{Level: 2}
+		w := receiveAfterAdvancing(t, clock, time.Millisecond*500, out)
+		assert.Equal(t, 3, w.Result)
+		assert.Equal(t, time.Second, w.End.Sub(w.Start))
+
+		close(in)
+		expectClosedChannel(true, out, t)
+	})
+
+	t.Run("when ctx is cancelled, the stream should be closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := TumblingWindow(ctx, make(chan meterReading), time.Second, sum, byEventTime)
+		cancel()
+		expectClosedChannel(true, out, t)
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(secs int) meterReading {
+		return meterReading{At: t0.Add(time.Duration(secs) * time.Second), Level: secs}
+	}
+	levels := func(acc []int, r meterReading) []int { return append(acc, r.Level) }
+
+	t.Run("when size or slide aren't positive, we should panic", func(t *testing.T) {
+		assert.Panics(t, func() { SlidingWindow(context.Background(), make(chan meterReading), time.Second, 0, levels) })
+	})
+
+	t.Run("when windows overlap, each item should count towards every window it falls in", func(t *testing.T) {
+		out := SlidingWindow(context.Background(), GenerateFromSlice(context.Background(), []meterReading{at(1), at(6), at(11)}),
+			time.Second*10, time.Second*5, levels, func(o *WindowOptions[meterReading]) {
+				o.EventTime = func(r meterReading) time.Time { return r.At }
+			})
+
+		var got []string
+		var results [][]int
+		for w := range out {
+			got = append(got, w.Start.Sub(t0).String()+"-"+w.End.Sub(t0).String())
+			results = append(results, w.Result)
+		}
+		assert.Equal(t, []string{"-5s-5s", "0s-10s", "5s-15s", "10s-20s"}, got)
+		assert.Equal(t, [][]int{{1}, {1, 6}, {6, 11}, {11}}, results)
+	})
+
+	t.Run("when windows hop, items in the gaps should be dropped without being counted as late", func(t *testing.T) {
+		var late []int
+		out := SlidingWindow(context.Background(), GenerateFromSlice(context.Background(), []meterReading{at(1), at(7), at(11)}),
+			time.Second*5, time.Second*10, levels, func(o *WindowOptions[meterReading]) {
+				o.EventTime = func(r meterReading) time.Time { return r.At }
+				o.OnLate = func(r meterReading) { late = append(late, r.Level) }
+			})
+
+		var results [][]int
+		for w := range out {
+			results = append(results, w.Result)
+		}
+		assert.Equal(t, [][]int{{1}, {11}}, results)
+		assert.Empty(t, late)
+	})
+}